@@ -9,4 +9,8 @@ import election "go_backend"
 
 var electionIndex = election.Index
 var electionCommonHeadersMiddleware = election.CommonHeadersMiddleware
+var electionCompressionMiddleware = election.CompressionMiddleware
 var electionNewAPIHandler = election.NewAPIHandler
+var electionAPIPatterns = election.APIPatterns
+var electionCacheResponses = election.CacheResponses
+var electionRecordCacheLookup = election.RecordCacheLookup
@@ -25,20 +25,17 @@ import (
 
 func init() {
 	index := httpsMiddleware(http.HandlerFunc(electionIndex))
+	// The cache is inside the rate limit of each route, so that cached
+	// responses are limited too.
+	electionCacheResponses(appEngineMiddleware)
 	api := httpsMiddleware(
 		electionCommonHeadersMiddleware(
-			appEngineMiddleware(
+			electionCompressionMiddleware(
 				electionNewAPIHandler())))
-	rootSlash := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// The "/" pattern matches everything, so we need to check
-		// that we're at the root here.
-		if r.URL.Path == "/" {
-			index.ServeHTTP(w, r)
-			return
-		}
-		api.ServeHTTP(w, r)
-	})
-	http.Handle("/", rootSlash)
+	for _, pattern := range electionAPIPatterns() {
+		http.Handle(pattern, api)
+	}
+	http.Handle("/", index)
 	http.Handle("/embed", index)
 }
 
@@ -26,21 +26,56 @@ import (
 )
 
 // To bulk invalidate memcache, increment this counter and re-deploy the app
-const cacheKey = "2016063002" //YYYYMMDDVV
+const cacheKey = "2016063005" //YYYYMMDDVV
+
+// cachedResponse is the value stored in memcache for a request. The content
+// type is kept since not every response is JSON (e.g. vector tiles), the
+// content encoding for precompressed responses, and the content disposition
+// of downloads (e.g. polling place exports). The ETag is added, and
+// conditional requests answered, outside the cache.
+type cachedResponse struct {
+	ContentType        string
+	ContentEncoding    string
+	ContentDisposition string
+	Body               []byte
+}
+
+func writeCachedResponse(w http.ResponseWriter, cr *cachedResponse) {
+	w.Header().Set("Cache-control", "public, max-age=120")
+	w.Header().Add("Vary", "Accept")
+	w.Header().Set("Content-type", cr.ContentType)
+	if cr.ContentEncoding != "" {
+		w.Header().Set("Content-Encoding", cr.ContentEncoding)
+	}
+	if cr.ContentDisposition != "" {
+		w.Header().Set("Content-Disposition", cr.ContentDisposition)
+	}
+	w.Write(cr.Body)
+}
 
 func appEngineMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			// Only GET responses are a function of the URL, anything
+			// else (e.g. batch location lookups) bypasses the cache, as
+			// do event streams, which mustn't be buffered.
+			if r.Method != "GET" || r.Header.Get("Accept") == "text/event-stream" {
+				h.ServeHTTP(w, r)
+				return
+			}
 			ctx := appengine.NewContext(r)
-			url := fmt.Sprintf("%s&cache_key=%s", r.URL.String(), cacheKey)
-			item, err := memcache.Get(ctx, url)
-			if err != nil {
+			// Responses are negotiated on the Accept and
+			// Accept-Encoding headers too.
+			url := fmt.Sprintf("%s&cache_key=%s&accept=%s&accept_encoding=%s", r.URL.String(), cacheKey,
+				r.Header.Get("Accept"), r.Header.Get("Accept-Encoding"))
+			var cr cachedResponse
+			_, err := memcache.Gob.Get(ctx, url, &cr)
+			if err != nil && err != memcache.ErrCacheMiss {
 				gaelog.Debugf(ctx, "Failed to get from memcache for %v: %v", url, err)
 			}
-			if item != nil {
-				w.Header().Set("Cache-control", "public, max-age=120")
-				w.Header().Set("Content-type", "application/json")
-				w.Write(item.Value)
+			electionRecordCacheLookup(err == nil)
+			if err == nil {
+				writeCachedResponse(w, &cr)
 				return
 			}
 			// TODO: this is overkill, and we don't actually copy the headers back
@@ -56,16 +91,33 @@ func appEngineMiddleware(h http.Handler) http.Handler {
 			if rw.Code != http.StatusOK {
 				errorMessage := rw.Body.String()
 				gaelog.Debugf(ctx, "Handler returned non-200 response for %v: %v", url, errorMessage)
-				http.Error(w, errorMessage, rw.Code)
+				// Error responses are JSON, pass them on as is.
+				w.Header().Set("Content-type", rw.HeaderMap.Get("Content-type"))
+				if retryAfter := rw.HeaderMap.Get("Retry-After"); retryAfter != "" {
+					w.Header().Set("Retry-After", retryAfter)
+				}
+				w.WriteHeader(rw.Code)
+				w.Write(rw.Body.Bytes())
 				return
 			}
-			err = memcache.Set(ctx, &memcache.Item{Key: url, Value: rw.Body.Bytes()})
+			if rw.HeaderMap.Get("Cache-control") == "no-store" {
+				// E.g. health checks, which are per instance.
+				w.Header().Set("Cache-control", "no-store")
+				w.Header().Set("Content-type", rw.HeaderMap.Get("Content-type"))
+				w.Write(rw.Body.Bytes())
+				return
+			}
+			cr = cachedResponse{
+				ContentType:        rw.HeaderMap.Get("Content-type"),
+				ContentEncoding:    rw.HeaderMap.Get("Content-Encoding"),
+				ContentDisposition: rw.HeaderMap.Get("Content-Disposition"),
+				Body:               rw.Body.Bytes(),
+			}
+			err = memcache.Gob.Set(ctx, &memcache.Item{Key: url, Object: cr})
 			if err != nil {
 				gaelog.Errorf(ctx, "Failed to set memcache for %v: %v", url, err)
 			}
-			w.Header().Set("Cache-control", "public, max-age=120")
-			w.Header().Set("Content-type", "application/json")
-			w.Write(rw.Body.Bytes())
+			writeCachedResponse(w, &cr)
 		})
 }
 
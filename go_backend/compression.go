@@ -0,0 +1,227 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content encodings supported by CompressionMiddleware, in order of
+// preference.
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// Compression levels for responses compressed on the fly, favouring speed,
+// and for precompressed responses, which are compressed only once.
+const (
+	dynamicGzipLevel         = gzip.DefaultCompression
+	dynamicBrotliQuality     = 4
+	precompressGzipLevel     = gzip.BestCompression
+	precompressBrotliQuality = 9
+)
+
+// MaxPrecompressedBytes bounds the memory used by precompressed responses.
+// Once reached, further responses are still compressed but not kept.
+const MaxPrecompressedBytes = 64 << 20
+
+// chooseContentEncoding returns the preferred encoding accepted by the
+// request, or "" for identity.
+func chooseContentEncoding(r *http.Request) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		value := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					value = v
+				}
+			}
+		}
+		q[coding] = value
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range []string{EncodingBrotli, EncodingGzip} {
+		v, ok := q[coding]
+		if !ok {
+			v, ok = q["*"]
+		}
+		if ok && v > bestQ {
+			best, bestQ = coding, v
+		}
+	}
+	return best
+}
+
+func newCompressor(w io.Writer, encoding string, precompress bool) io.WriteCloser {
+	if encoding == EncodingBrotli {
+		quality := dynamicBrotliQuality
+		if precompress {
+			quality = precompressBrotliQuality
+		}
+		return brotli.NewWriterLevel(w, quality)
+	}
+	level := dynamicGzipLevel
+	if precompress {
+		level = precompressGzipLevel
+	}
+	// The level is one of the constants above, so this can't fail.
+	gw, _ := gzip.NewWriterLevel(w, level)
+	return gw
+}
+
+// compressResponseWriter compresses the body of 200 responses, unless the
+// handler already set a Content-Encoding (i.e. the body is precompressed).
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	compressor  io.WriteCloser
+	wroteHeader bool
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if code == http.StatusOK && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.compressor = newCompressor(w.ResponseWriter, w.encoding, false)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.compressor == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.compressor.Write(b)
+}
+
+// Flush sends what has been compressed so far, for streamed responses.
+func (w *compressResponseWriter) Flush() {
+	if f, ok := w.compressor.(interface {
+		Flush() error
+	}); ok {
+		f.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressResponseWriter) close() {
+	if w.compressor != nil {
+		w.compressor.Close()
+	}
+}
+
+// CompressionMiddleware compresses responses with brotli or gzip, as
+// negotiated with the request's Accept-Encoding header, and delegates to h
+// for further processing of the request.
+func CompressionMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := chooseContentEncoding(r)
+			if encoding == "" || r.Method == "HEAD" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+			defer cw.close()
+			h.ServeHTTP(cw, r)
+		})
+}
+
+// precompressedCache holds compressed responses which never change while the
+// application runs, such as electorate geometry.
+type precompressedCache struct {
+	sync.RWMutex
+	responses map[string][]byte
+	size      int
+}
+
+var precompressed = &precompressedCache{responses: make(map[string][]byte)}
+
+func (c *precompressedCache) get(key string) ([]byte, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	b, ok := c.responses[key]
+	return b, ok
+}
+
+func (c *precompressedCache) add(key string, b []byte) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.responses[key]; ok || c.size+len(b) > MaxPrecompressedBytes {
+		return
+	}
+	c.responses[key] = b
+	c.size += len(b)
+}
+
+// writePrecompressed writes a response for an immutable resource identified
+// by key, compressed with the encoding negotiated for the request. encode is
+// only called if the compressed response isn't already cached. It returns
+// false, without writing anything, if the request doesn't accept a
+// compressed response.
+func writePrecompressed(w http.ResponseWriter, r *http.Request, key string, contentType string, encode func(w io.Writer) error) (bool, error) {
+	encoding := chooseContentEncoding(r)
+	if encoding == "" {
+		return false, nil
+	}
+	key = fmt.Sprintf("%v;%v", key, encoding)
+	b, ok := precompressed.get(key)
+	if !ok {
+		var buf bytes.Buffer
+		compressor := newCompressor(&buf, encoding, true)
+		if err := encode(compressor); err != nil {
+			return true, err
+		}
+		if err := compressor.Close(); err != nil {
+			return true, err
+		}
+		b = buf.Bytes()
+		precompressed.add(key, b)
+	}
+	w.Header().Set("Cache-control", "public, max-age=120")
+	w.Header().Set("Content-type", contentType)
+	w.Header().Set("Content-Encoding", encoding)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+	return true, nil
+}
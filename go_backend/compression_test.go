@@ -0,0 +1,102 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChooseContentEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip, deflate", EncodingGzip},
+		{"gzip, deflate, br", EncodingBrotli},
+		{"br;q=0.5, gzip", EncodingGzip},
+		{"br;q=0, *", EncodingGzip},
+		{"*", EncodingBrotli},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/zoom_buckets", nil)
+		r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		if got := chooseContentEncoding(r); got != tt.expected {
+			t.Errorf("Accept-Encoding %q: expected %q, got %q", tt.acceptEncoding, tt.expected, got)
+		}
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	const body = `{"type":"FeatureCollection","features":[]}`
+	h := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	r, _ := http.NewRequest("GET", "/polling_places?ids=banks", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Header().Get("Content-Encoding") != EncodingGzip {
+		t.Fatalf("Expected gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	gr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != body {
+		t.Errorf("Expected %q, got %q", body, b)
+	}
+}
+
+func TestWritePrecompressed(t *testing.T) {
+	calls := 0
+	encode := func(w io.Writer) error {
+		calls++
+		_, err := w.Write([]byte("[]"))
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		r, _ := http.NewRequest("GET", "/electorates/6?ids=all", nil)
+		r.Header.Set("Accept-Encoding", "br")
+		w := httptest.NewRecorder()
+		handled, err := writePrecompressed(w, r, "test;precompressed", "application/json", encode)
+		if !handled || err != nil {
+			t.Fatalf("Expected a precompressed response, got %v, %v", handled, err)
+		}
+		if w.Header().Get("Content-Encoding") != EncodingBrotli {
+			t.Errorf("Expected br encoding, got %q", w.Header().Get("Content-Encoding"))
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the response to be encoded once, was encoded %v times", calls)
+	}
+	r, _ := http.NewRequest("GET", "/electorates/6?ids=all", nil)
+	if handled, _ := writePrecompressed(httptest.NewRecorder(), r, "test;precompressed", "application/json", encode); handled {
+		t.Error("Expected no precompressed response without Accept-Encoding")
+	}
+}
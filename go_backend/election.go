@@ -40,7 +40,13 @@ func processLocale(locale string) string {
 
 // Index is the http.HandlerFunc that serve the index.html page of our application.
 // It's exported because it is handled differently to the other API handlers.
+// It serves the root and /embed only, as it's mounted at "/" which matches
+// everything not matched by APIPatterns.
 func Index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/embed" {
+		http.NotFound(w, r)
+		return
+	}
 	// TODO: A bit ugly, needed because of addCommonHeaders applied globally.
 	w.Header().Del("Content-Disposition")
 	data := struct {
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	shp "github.com/jonas-p/go-shp"
+)
+
+// electorateExporter writes the geometry of electorates at a zoom level, with
+// their name, state and area, in a format for GIS software.
+type electorateExporter struct {
+	ContentType string
+	// Extension is that of the downloaded file, which differs from the format
+	// for zipped shapefiles.
+	Extension   string
+	Description string
+	// Export writes a layer or file set called name.
+	Export func(w io.Writer, name string, zoom ZoomLevel, ids []ElectorateID) error
+}
+
+// electorateExportFormats are the keys of electorateExporters, the first
+// being the default.
+var electorateExportFormats = []string{ExportKML, ExportShapefile, ExportGeoPackage}
+
+var electorateExporters = map[string]*electorateExporter{
+	ExportKML: {
+		ContentType: "application/vnd.google-earth.kml+xml",
+		Extension:   "kml",
+		Description: "KML, a placemark per electorate",
+		Export:      exportElectoratesKML,
+	},
+	ExportShapefile: {
+		ContentType: "application/zip",
+		Extension:   "zip",
+		Description: "A zipped ESRI Shapefile, with .shp, .shx, .dbf, .prj and .cpg files",
+		Export:      exportElectoratesShapefile,
+	},
+	ExportGeoPackage: {
+		ContentType: "application/geopackage+sqlite3",
+		Extension:   "gpkg",
+		Description: "An OGC GeoPackage, with a feature table",
+		Export:      exportElectoratesGeoPackage,
+	},
+}
+
+// polygonRings returns the rings of ep, the first being its exterior, as the
+// GeoJSON features have them.
+func polygonRings(ep *ElectoratePolygon) [][]shp.Point {
+	var rings [][]shp.Point
+	parts := append(ep.Parts, ep.NumPoints)
+	for i := 1; i <= int(ep.NumParts); i++ {
+		rings = append(rings, ep.Points[parts[i-1]:parts[i]])
+	}
+	return rings
+}
+
+// polygonsBox returns the bounding box of the polygons of eps.
+func polygonsBox(eps []*ElectoratePolygon) shp.Box {
+	var box shp.Box
+	for i, ep := range eps {
+		if i == 0 {
+			box = ep.Box
+		} else {
+			box.Extend(ep.Box)
+		}
+	}
+	return box
+}
+
+func kmlCoordinates(ring []shp.Point) string {
+	coordinates := make([]string, len(ring))
+	for i, p := range ring {
+		coordinates[i] = strconv.FormatFloat(p.X, 'f', -1, 64) + "," + strconv.FormatFloat(p.Y, 'f', -1, 64)
+	}
+	return strings.Join(coordinates, " ")
+}
+
+func exportElectoratesKML(w io.Writer, name string, zoom ZoomLevel, ids []ElectorateID) error {
+	doc := &kml{Name: name}
+	for _, id := range ids {
+		e := electorates[id]
+		placemark := kmlPlacemark{
+			ID:   string(e.id),
+			Name: e.name,
+			ExtendedData: []kmlData{
+				{"id", string(e.id)},
+				{"name", e.name},
+				{"state", e.state},
+				{"area_sqkm", strconv.FormatFloat(float64(e.areaSqkm), 'f', -1, 32)},
+			},
+		}
+		for _, ep := range e.polygons[zoom] {
+			rings := polygonRings(ep)
+			if len(rings) == 0 {
+				continue
+			}
+			polygon := kmlPolygon{Outer: kmlBoundary{kmlCoordinates(rings[0])}}
+			for _, ring := range rings[1:] {
+				polygon.Inner = append(polygon.Inner, kmlBoundary{kmlCoordinates(ring)})
+			}
+			placemark.MultiGeometry = append(placemark.MultiGeometry, polygon)
+		}
+		doc.Placemarks = append(doc.Placemarks, placemark)
+	}
+	return writeXML(w, doc)
+}
+
+func electoratesExportQuery(w http.ResponseWriter, r *http.Request) {
+	zoom, _, err := parseZoom(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ids := r.FormValue("ids")
+	if ids == "" {
+		writeError(w, newBadRequestError(CodeMissingParameter, "ids", "", "No electorate ID specified"))
+		return
+	}
+	format := r.FormValue("format")
+	if format == "" {
+		format = electorateExportFormats[0]
+	}
+	exporter := electorateExporters[format]
+	if exporter == nil {
+		writeError(w, newBadRequestError(CodeInvalidFormat, "format", format,
+			"Expected format to be one of %v", strings.Join(electorateExportFormats, ", ")))
+		return
+	}
+	electorateIds, err := queryElectorateIDs(zoom, ids)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	name := fmt.Sprintf("electorates_%v", zoom)
+	encode := func(w io.Writer) error {
+		return exporter.Export(w, name, zoom, electorateIds)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%v.%v"`, name, exporter.Extension))
+	// As with electoratesQuery, exports never change so compressed ones are
+	// kept.
+	key := fmt.Sprintf("electorates_export;%v;%v;%v", zoom, canonicalElectorateIDs(ids), format)
+	handled, err := writePrecompressed(w, r, key, exporter.ContentType, encode)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if handled {
+		return
+	}
+	w.Header().Set("Cache-control", "public, max-age=120")
+	w.Header().Set("Content-type", exporter.ContentType)
+	if err := encode(w); err != nil {
+		writeError(w, err)
+	}
+}
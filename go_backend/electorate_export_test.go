@@ -0,0 +1,179 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	shp "github.com/jonas-p/go-shp"
+)
+
+// withExportElectorates sets electorates to two squares at zoom 5, Solomon
+// with a hole, for the duration of f.
+func withExportElectorates(f func()) {
+	saved := electorates
+	defer func() { electorates = saved }()
+	square := func(minX, minY, size float64, hole bool) *ElectoratePolygon {
+		points := []shp.Point{
+			{X: minX, Y: minY}, {X: minX, Y: minY + size}, {X: minX + size, Y: minY + size},
+			{X: minX + size, Y: minY}, {X: minX, Y: minY},
+		}
+		parts := []int32{0}
+		if hole {
+			parts = append(parts, int32(len(points)))
+			mid := minX + size/2
+			points = append(points, shp.Point{X: mid, Y: minY + 0.1}, shp.Point{X: mid + 0.1, Y: minY + 0.1},
+				shp.Point{X: mid + 0.1, Y: minY + 0.2}, shp.Point{X: mid, Y: minY + 0.1})
+		}
+		return &ElectoratePolygon{Polygon: &shp.Polygon{
+			Box:       shp.BBoxFromPoints(points),
+			NumParts:  int32(len(parts)),
+			NumPoints: int32(len(points)),
+			Parts:     parts,
+			Points:    points,
+		}}
+	}
+	electorates = map[ElectorateID]*Electorate{
+		"lingiari": {id: "lingiari", name: "Lingiari", state: "NT", areaSqkm: 1348094,
+			polygons: map[ZoomLevel][]*ElectoratePolygon{5: {square(129, -26, 9, false), square(130, -12, 1, false)}}},
+		"solomon": {id: "solomon", name: "Solomon", state: "NT", areaSqkm: 336.5,
+			polygons: map[ZoomLevel][]*ElectoratePolygon{5: {square(130.8, -12.5, 0.4, true)}}},
+	}
+	f()
+}
+
+func TestExportElectoratesShapefile(t *testing.T) {
+	withExportElectorates(func() {
+		f, err := ioutil.TempFile("", "electorates")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if err := exportElectoratesShapefile(f, "electorates_5", 5, []ElectorateID{"lingiari", "solomon"}); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		r, err := shp.OpenZip(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		expected := []struct {
+			name, area string
+			parts      int32
+		}{{"Lingiari", "1348094.0", 2}, {"Solomon", "336.5", 2}}
+		var i int
+		for ; r.Next(); i++ {
+			_, shape := r.Shape()
+			polygon, ok := shape.(*shp.Polygon)
+			if !ok || i >= len(expected) {
+				t.Fatalf("Unexpected shape %v: %#v", i, shape)
+			}
+			if polygon.NumParts != expected[i].parts || r.Attribute(1) != expected[i].name || r.Attribute(3) != expected[i].area {
+				t.Errorf("Expected %+v, got %v parts, %v and %v", expected[i], polygon.NumParts, r.Attribute(1), r.Attribute(3))
+			}
+		}
+		if err := r.Err(); err != nil || i != len(expected) {
+			t.Errorf("Expected %v shapes, got %v: %v", len(expected), i, err)
+		}
+	})
+}
+
+func TestExportElectoratesGeoPackage(t *testing.T) {
+	withExportElectorates(func() {
+		var b bytes.Buffer
+		if err := exportElectoratesGeoPackage(&b, "electorates_5", 5, []ElectorateID{"lingiari", "solomon"}); err != nil {
+			t.Fatal(err)
+		}
+		db := b.Bytes()
+		if !bytes.HasPrefix(db, []byte("SQLite format 3\x00")) {
+			t.Fatalf("Expected a SQLite database, got %q", db[:16])
+		}
+		pages := binary.BigEndian.Uint32(db[28:])
+		if len(db) != int(pages)*sqlitePageSize {
+			t.Errorf("Expected %v pages, got %v bytes", pages, len(db))
+		}
+		if id := binary.BigEndian.Uint32(db[68:]); id != geoPackageApplicationID {
+			t.Errorf("Expected application ID GPKG, got %x", id)
+		}
+		r := &sqliteReader{t: t, db: db}
+		schema := r.schema()
+		for _, name := range []string{"gpkg_spatial_ref_sys", "gpkg_contents", "gpkg_geometry_columns", "electorates_5",
+			"sqlite_autoindex_gpkg_contents_1", "sqlite_autoindex_gpkg_contents_2",
+			"sqlite_autoindex_gpkg_geometry_columns_1", "sqlite_autoindex_gpkg_geometry_columns_2"} {
+			if schema[name] == nil {
+				t.Errorf("Expected %v in the schema", name)
+			}
+		}
+		rows := r.rows(uint32(schema["electorates_5"][3].(int64)))
+		if len(rows) != 2 {
+			t.Fatalf("Expected 2 features, got %v", len(rows))
+		}
+		geom := geoPackageGeometry(electorates["solomon"].polygons[5])
+		expected := []interface{}{nil, geom, "solomon", "Solomon", "NT", 336.5}
+		if rows[1].rowID != 2 || !reflect.DeepEqual(rows[1].values, expected) {
+			t.Errorf("Expected Solomon, got %v: %v", rows[1].rowID, rows[1].values)
+		}
+	})
+}
+
+func TestExportElectoratesKML(t *testing.T) {
+	withExportElectorates(func() {
+		var b bytes.Buffer
+		if err := exportElectoratesKML(&b, "electorates_5", 5, []ElectorateID{"lingiari", "solomon"}); err != nil {
+			t.Fatal(err)
+		}
+		var k kml
+		if err := xml.Unmarshal(b.Bytes(), &k); err != nil {
+			t.Fatal(err)
+		}
+		if len(k.Placemarks) != 2 || len(k.Placemarks[0].MultiGeometry) != 2 {
+			t.Fatalf("Expected Lingiari's 2 polygons and Solomon, got %+v", k.Placemarks)
+		}
+		solomon := k.Placemarks[1].MultiGeometry[0]
+		if len(solomon.Inner) != 1 || !strings.HasPrefix(solomon.Outer.Coordinates, "130.8,-12.5 130.8,-12.1 ") {
+			t.Errorf("Expected Solomon with a hole, got %+v", solomon)
+		}
+	})
+}
+
+func TestAppendSQLiteVarint(t *testing.T) {
+	tests := []struct {
+		v        uint64
+		expected []byte
+	}{
+		{0, []byte{0}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0}},
+		{16383, []byte{0xff, 0x7f}},
+		{1 << 56, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0}},
+		{1<<64 - 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		if b := appendSQLiteVarint(nil, tt.v); !bytes.Equal(b, tt.expected) {
+			t.Errorf("%v: expected %x, got %x", tt.v, tt.expected, b)
+		}
+	}
+}
@@ -105,15 +105,20 @@ func convertToEncodedPolylineFeature(f *geojson.Feature) (*EncodedPolylineFeatur
 	}, true
 }
 
+// maybeConvertToEncodedPolylineFeature returns f with its geometry encoded,
+// or f itself if it isn't a MultiPolygon.
+func maybeConvertToEncodedPolylineFeature(f *geojson.Feature) MarshalJSON {
+	epf, ok := convertToEncodedPolylineFeature(f)
+	if !ok {
+		return f
+	}
+	return epf
+}
+
 func maybeConvertToEncodedPolylineFeatureCollection(fc *geojson.FeatureCollection) MarshalJSON {
 	var features []MarshalJSON
 	for _, f := range fc.Features {
-		epf, ok := convertToEncodedPolylineFeature(f)
-		if !ok {
-			features = append(features, f)
-			continue
-		}
-		features = append(features, epf)
+		features = append(features, maybeConvertToEncodedPolylineFeature(f))
 	}
 	return &EncodedPolylineFeatureCollection{
 		Type:        fc.Type,
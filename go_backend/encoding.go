@@ -0,0 +1,257 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// Formats which feature collections can be encoded in, as given in the
+// 'format' parameter.
+const (
+	FormatGeoJSON         = "geojson"
+	FormatEncodedPolyline = "encoded_polyline"
+	FormatGeobuf          = "geobuf"
+	FormatTopoJSON        = "topojson"
+)
+
+// FeatureCollectionEncoder writes a feature collection in a given
+// representation. EncodeFeature is set for JSON formats whose features can
+// be written one at a time, see streamFeatureCollection.
+type FeatureCollectionEncoder struct {
+	Format        string
+	ContentType   string
+	Encode        func(w io.Writer, fc *geojson.FeatureCollection) error
+	EncodeFeature func(f *geojson.Feature) MarshalJSON
+}
+
+func encodeJSON(w io.Writer, v MarshalJSON) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// featureCollectionEncoders is the registry of every supported format. Each
+// handler chooses the subset of formats that make sense for its response.
+var featureCollectionEncoders = map[string]*FeatureCollectionEncoder{
+	FormatGeoJSON: {
+		Format:      FormatGeoJSON,
+		ContentType: "application/geo+json",
+		Encode: func(w io.Writer, fc *geojson.FeatureCollection) error {
+			return encodeJSON(w, fc)
+		},
+		EncodeFeature: func(f *geojson.Feature) MarshalJSON {
+			return f
+		},
+	},
+	FormatEncodedPolyline: {
+		Format:      FormatEncodedPolyline,
+		ContentType: "application/vnd.election.encoded-polyline+json",
+		Encode: func(w io.Writer, fc *geojson.FeatureCollection) error {
+			return encodeJSON(w, maybeConvertToEncodedPolylineFeatureCollection(fc))
+		},
+		EncodeFeature: maybeConvertToEncodedPolylineFeature,
+	},
+	FormatGeobuf: {
+		Format:      FormatGeobuf,
+		ContentType: "application/vnd.geobuf",
+		Encode: func(w io.Writer, fc *geojson.FeatureCollection) error {
+			b, err := encodeGeobuf(fc)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(b)
+			return err
+		},
+	},
+	FormatTopoJSON: {
+		Format:      FormatTopoJSON,
+		ContentType: "application/topo+json",
+		Encode: func(w io.Writer, fc *geojson.FeatureCollection) error {
+			topology, err := convertToTopology(fc)
+			if err != nil {
+				return err
+			}
+			return encodeJSON(w, topology)
+		},
+	},
+}
+
+// Formats supported by each handler, the first being the default.
+var (
+	electorateFormats   = []string{FormatEncodedPolyline, FormatGeoJSON, FormatGeobuf, FormatTopoJSON}
+	viewportFormats     = []string{FormatGeoJSON, FormatEncodedPolyline, FormatGeobuf}
+	pollingPlaceFormats = []string{FormatGeoJSON, FormatEncodedPolyline, FormatGeobuf}
+)
+
+type acceptedMediaType struct {
+	mediaType string
+	q         float64
+}
+
+type byQuality []acceptedMediaType
+
+func (a byQuality) Len() int           { return len(a) }
+func (a byQuality) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byQuality) Less(i, j int) bool { return a[i].q > a[j].q }
+
+// parseAccept returns the media types of an Accept header, highest quality
+// first.
+func parseAccept(accept string) []acceptedMediaType {
+	var mediaTypes []acceptedMediaType
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			mediaTypes = append(mediaTypes, acceptedMediaType{mediaType, q})
+		}
+	}
+	sort.Stable(byQuality(mediaTypes))
+	return mediaTypes
+}
+
+// chooseEncoder picks the encoder for a request out of formats: the 'format'
+// parameter takes precedence over the Accept header, and generic JSON or
+// wildcard media types (or no preference at all) get the first format.
+func chooseEncoder(r *http.Request, formats []string) (*FeatureCollectionEncoder, error) {
+	if format := r.FormValue("format"); format != "" {
+		for _, f := range formats {
+			if f == format {
+				return featureCollectionEncoders[f], nil
+			}
+		}
+		return nil, fmt.Errorf("Unsupported format %v", format)
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return featureCollectionEncoders[formats[0]], nil
+	}
+	for _, mt := range parseAccept(accept) {
+		switch mt.mediaType {
+		case "*/*", "application/*", "application/json":
+			return featureCollectionEncoders[formats[0]], nil
+		}
+		for _, f := range formats {
+			if featureCollectionEncoders[f].ContentType == mt.mediaType {
+				return featureCollectionEncoders[f], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("No acceptable format in %v", accept)
+}
+
+// negotiateEncoder chooses the encoder for the request out of formats, or
+// writes an error response and returns nil.
+func negotiateEncoder(w http.ResponseWriter, r *http.Request, formats []string) *FeatureCollectionEncoder {
+	w.Header().Add("Vary", "Accept")
+	encoder, err := chooseEncoder(r, formats)
+	if err == nil {
+		return encoder
+	}
+	if format := r.FormValue("format"); format != "" {
+		writeError(w, newBadRequestError(CodeInvalidFormat, "format", format,
+			"Expected format to be one of %v", strings.Join(formats, ", ")))
+		return nil
+	}
+	writeError(w, &APIError{
+		Status:  http.StatusNotAcceptable,
+		Code:    CodeNotAcceptable,
+		Message: fmt.Sprintf("Expected Accept to include a media type for one of %v", strings.Join(formats, ", ")),
+		Field:   "Accept",
+		Value:   r.Header.Get("Accept"),
+	})
+	return nil
+}
+
+// writeFeatureCollection writes fc in the format negotiated for the request.
+func writeFeatureCollection(w http.ResponseWriter, r *http.Request, fc *geojson.FeatureCollection, formats []string) {
+	encoder := negotiateEncoder(w, r, formats)
+	if encoder == nil {
+		return
+	}
+	writeFeatureCollectionWithEncoder(w, fc, encoder)
+}
+
+func writeFeatureCollectionWithEncoder(w http.ResponseWriter, fc *geojson.FeatureCollection, encoder *FeatureCollectionEncoder) {
+	w.Header().Set("Cache-control", "public, max-age=120")
+	w.Header().Set("Content-type", encoder.ContentType)
+	err := encoder.Encode(w, fc)
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// streamFeatureCollection writes a JSON feature collection of n features,
+// identical to that of encoder.Encode, one feature at a time so that the
+// whole collection is never in memory. The bounding box has to be known up
+// front, as it comes before the features.
+func streamFeatureCollection(w io.Writer, encoder *FeatureCollectionEncoder, bbox []float64, n int, feature func(i int) (*geojson.Feature, error)) error {
+	if _, err := io.WriteString(w, `{"type":"FeatureCollection",`); err != nil {
+		return err
+	}
+	if len(bbox) != 0 {
+		b, err := json.Marshal(bbox)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, `"bbox":%s,`, b); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, `"features":[`); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		f, err := feature(i)
+		if err != nil {
+			return err
+		}
+		b, err := encoder.EncodeFeature(f).MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
@@ -0,0 +1,117 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/paulmach/go.geojson"
+)
+
+func TestChooseEncoder(t *testing.T) {
+	tests := []struct {
+		url      string
+		accept   string
+		expected string
+	}{
+		{"/electorates/6?ids=all", "", FormatEncodedPolyline},
+		{"/electorates/6?ids=all", "*/*", FormatEncodedPolyline},
+		{"/electorates/6?ids=all", "application/json", FormatEncodedPolyline},
+		{"/electorates/6?ids=all", "application/geo+json", FormatGeoJSON},
+		{"/electorates/6?ids=all", "application/vnd.geobuf;q=0.9, application/topo+json", FormatTopoJSON},
+		{"/electorates/6?ids=all", "application/vnd.geobuf, */*;q=0.1", FormatGeobuf},
+		{"/electorates/6?ids=all&format=geojson", "application/vnd.geobuf", FormatGeoJSON},
+		{"/electorates/6?ids=all", "text/html", ""},
+		{"/electorates/6?ids=all&format=shp", "", ""},
+	}
+	for _, tt := range tests {
+		r, err := http.NewRequest("GET", tt.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		encoder, err := chooseEncoder(r, electorateFormats)
+		if tt.expected == "" {
+			if err == nil {
+				t.Errorf("%v (Accept: %v): expected an error, got %v", tt.url, tt.accept, encoder.Format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v (Accept: %v): %v", tt.url, tt.accept, err)
+			continue
+		}
+		if encoder.Format != tt.expected {
+			t.Errorf("%v (Accept: %v): expected %v, got %v", tt.url, tt.accept, tt.expected, encoder.Format)
+		}
+	}
+}
+
+func TestEncodeGeobufPolygon(t *testing.T) {
+	fc := geojson.NewFeatureCollection()
+	fc.AddFeature(squareFeature("a", 0, 0))
+	b, err := encodeGeobuf(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A single ring needs no lengths, and the closing point is omitted:
+	// 4 points, delta encoded and scaled by 10^6.
+	var g []byte
+	g = appendVarintField(g, 1, 5)
+	g = appendPackedSint64Field(g, 3, []int64{0, 0, 1e6, 0, 0, 1e6, -1e6, 0})
+	var f []byte
+	f = appendBytesField(f, 1, g)
+	f = appendBytesField(f, 11, []byte("a"))
+	var expected []byte
+	expected = appendVarintField(expected, 3, GeobufPrecision)
+	expected = appendBytesField(expected, 4, appendBytesField(nil, 1, f))
+	if string(b) != string(expected) {
+		t.Errorf("Expected %v, got %v", expected, b)
+	}
+}
+
+func TestStreamFeatureCollection(t *testing.T) {
+	fc := geojson.NewFeatureCollection()
+	fc.AddFeature(squareFeature("a", 0, 0))
+	point := geojson.NewPointFeature([]float64{0.5, 0.5})
+	point.Properties["name"] = "<b>"
+	fc.AddFeature(point)
+	fc.AddFeature(squareFeature("b", 1, 0))
+	fc.BoundingBox = []float64{0, 0, 2, 1}
+	for _, format := range []string{FormatGeoJSON, FormatEncodedPolyline} {
+		encoder := featureCollectionEncoders[format]
+		var expected, streamed bytes.Buffer
+		if err := encoder.Encode(&expected, fc); err != nil {
+			t.Fatal(err)
+		}
+		err := streamFeatureCollection(&streamed, encoder, fc.BoundingBox, len(fc.Features),
+			func(i int) (*geojson.Feature, error) {
+				return fc.Features[i], nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if streamed.String() != expected.String() {
+			t.Errorf("%v: expected %s, got %s", format, expected.String(), streamed.String())
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Error codes returned in the 'code' field of error responses. These are
+// stable: clients and monitoring may rely on them, so existing codes must not
+// be renamed or reused for different errors.
+const (
+	// CodeMissingParameter: a required parameter was not given.
+	CodeMissingParameter = "MISSING_PARAMETER"
+	// CodeInvalidParameter: a parameter has an invalid value.
+	CodeInvalidParameter = "INVALID_PARAMETER"
+	// CodeInvalidZoom: the zoom level isn't an integer.
+	CodeInvalidZoom = "INVALID_ZOOM"
+	// CodeInvalidBbox: the bbox isn't 'lat,lng,lat,lng' or is empty.
+	CodeInvalidBbox = "INVALID_BBOX"
+	// CodeInvalidLocation: the location isn't 'lat,lng'.
+	CodeInvalidLocation = "INVALID_LOCATION"
+	// CodeUnknownElectorate: an electorate ID doesn't exist.
+	CodeUnknownElectorate = "UNKNOWN_ELECTORATE"
+	// CodeAllNotAllowedAtZoom: ids=all was requested above
+	// MaxZoomForAllElectorates.
+	CodeAllNotAllowedAtZoom = "ALL_NOT_ALLOWED_AT_ZOOM"
+	// CodeLocationNotInElectorate: the location isn't in any electorate.
+	CodeLocationNotInElectorate = "LOCATION_NOT_IN_ELECTORATE"
+	// CodeUnknownPostcode: there's no data for the postcode.
+	CodeUnknownPostcode = "UNKNOWN_POSTCODE"
+	// CodeUnknownSuburb: there's no data for the suburb.
+	CodeUnknownSuburb = "UNKNOWN_SUBURB"
+	// CodeInvalidTile: the tile coordinates are out of range.
+	CodeInvalidTile = "INVALID_TILE"
+	// CodeQueryTooComplex: a GraphQL query is nested too deeply or would
+	// resolve too many fields.
+	CodeQueryTooComplex = "QUERY_TOO_COMPLEX"
+	// CodeInvalidBody: the request body couldn't be decoded.
+	CodeInvalidBody = "INVALID_BODY"
+	// CodeInvalidFormat: the format parameter isn't supported by the
+	// endpoint.
+	CodeInvalidFormat = "INVALID_FORMAT"
+	// CodeNotAcceptable: none of the media types in the Accept header are
+	// supported by the endpoint.
+	CodeNotAcceptable = "NOT_ACCEPTABLE"
+	// CodeNotFound: there is no such endpoint.
+	CodeNotFound = "NOT_FOUND"
+	// CodeUnauthorized: the request needs a key it doesn't have.
+	CodeUnauthorized = "UNAUTHORIZED"
+	// CodeRateLimited: the client made too many requests, and should retry
+	// after the number of seconds in the Retry-After header.
+	CodeRateLimited = "RATE_LIMITED"
+	// CodeNotReady: the dataset is still loading, retry after the number of
+	// seconds in the Retry-After header.
+	CodeNotReady = "NOT_READY"
+	// CodeInternalError: the server failed to produce a response.
+	CodeInternalError = "INTERNAL_ERROR"
+)
+
+// APIError is the JSON envelope of every error response. Field and Value
+// identify the offending parameter and its value, where there is one.
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+	Value   string `json:"value,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// Extensions returns the code, field and value of e for GraphQL errors.
+func (e *APIError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.Code}
+	if e.Field != "" {
+		extensions["field"] = e.Field
+		extensions["value"] = e.Value
+	}
+	return extensions
+}
+
+// newBadRequestError returns a 400 APIError for the given parameter.
+func newBadRequestError(code, field, value string, format string, a ...interface{}) *APIError {
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+		Field:   field,
+		Value:   value,
+	}
+}
+
+func newUnknownElectorateError(id string) *APIError {
+	return newBadRequestError(CodeUnknownElectorate, "ids", id, "Electorate %v does not exist", id)
+}
+
+// newUnknownAreaError returns a 404 APIError for a postcode or suburb.
+func newUnknownAreaError(code, field, value string) *APIError {
+	return &APIError{
+		Status:  http.StatusNotFound,
+		Code:    code,
+		Message: fmt.Sprintf("No polling places or boundaries found for %v", value),
+		Field:   field,
+		Value:   value,
+	}
+}
+
+func newLocationNotInElectorateError(location string) *APIError {
+	return &APIError{
+		Status:  http.StatusNotFound,
+		Code:    CodeLocationNotInElectorate,
+		Message: "The location isn't in any electorate",
+		Field:   "location",
+		Value:   location,
+	}
+}
+
+// writeError writes err as a JSON error response. Errors other than
+// *APIError are internal, their message isn't exposed to the client.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		apiErr = &APIError{
+			Status:  http.StatusInternalServerError,
+			Code:    CodeInternalError,
+			Message: "Internal server error",
+		}
+	}
+	w.Header().Del("Cache-control")
+	w.Header().Del("Content-Encoding")
+	w.Header().Del("Content-Disposition")
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(apiErr)
+}
+
+// notFoundHandler is used for unknown routes.
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeError(w, &APIError{
+		Status:  http.StatusNotFound,
+		Code:    CodeNotFound,
+		Message: fmt.Sprintf("No endpoint for %v", r.URL.Path),
+	})
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err      error
+		status   int
+		expected APIError
+	}{
+		{
+			newBadRequestError(CodeInvalidBbox, "bbox", "1,2", "Invalid bbox"),
+			http.StatusBadRequest,
+			APIError{Code: CodeInvalidBbox, Message: "Invalid bbox", Field: "bbox", Value: "1,2"},
+		},
+		{
+			newUnknownElectorateError("gotham"),
+			http.StatusBadRequest,
+			APIError{Code: CodeUnknownElectorate, Message: "Electorate gotham does not exist", Field: "ids", Value: "gotham"},
+		},
+		{
+			// Internal errors must not leak their message.
+			fmt.Errorf("open dist/national_elb: no such file"),
+			http.StatusInternalServerError,
+			APIError{Code: CodeInternalError, Message: "Internal server error"},
+		},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		writeError(w, tt.err)
+		if w.Code != tt.status {
+			t.Errorf("%v: expected status %v, got %v", tt.err, tt.status, w.Code)
+		}
+		if ct := w.Header().Get("Content-type"); ct != "application/json" {
+			t.Errorf("%v: expected JSON, got %v", tt.err, ct)
+		}
+		var got APIError
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.expected {
+			t.Errorf("Expected %+v, got %+v", tt.expected, got)
+		}
+	}
+}
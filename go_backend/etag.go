@@ -0,0 +1,179 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ResponseVersion is mixed into every ETag. Increment it whenever the code
+// changes the content of responses for the same dataset, to invalidate
+// clients' cached copies.
+const ResponseVersion = "2016063003"
+
+// datasetVersion is a hash of all the data loaded by initSpatial. As the
+// dataset never changes while the application runs, together with
+// ResponseVersion it identifies the content of any response to a given
+// request.
+var datasetVersion uint64
+
+func hashFloat(h hash.Hash64, f float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+	h.Write(b[:])
+}
+
+// computeDatasetVersion hashes the electorates' metadata and geometry at
+// every zoom level, the polling places and their clustering.
+func computeDatasetVersion() uint64 {
+	h := fnv.New64a()
+	for _, z := range zoomBuckets {
+		fmt.Fprintf(h, "zoom:%v;", z)
+	}
+	var ids []string
+	for id := range electorates {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		e := electorates[ElectorateID(id)]
+		fmt.Fprintf(h, "electorate:%v;%v;%v;%v;", e.id, e.name, e.state, e.areaSqkm)
+		for _, z := range zoomBuckets {
+			for _, ep := range e.polygons[z] {
+				fmt.Fprintf(h, "polygon:%v;%v;%v;%v;", ep.gisid, ep.centLong, ep.centLat, ep.Parts)
+				for _, p := range ep.Points {
+					hashFloat(h, p.X)
+					hashFloat(h, p.Y)
+				}
+			}
+		}
+		for _, pg := range e.pplaceGrps {
+			fmt.Fprintf(h, "group:%v;%v;%v;", pg.ID(), pg.Lng, pg.Lat)
+		}
+	}
+	for i, p := range pollingPlaces {
+		fmt.Fprintf(h, "place:%v;%v;", p, pollingPlaceMinZoom[i])
+	}
+	return h.Sum64()
+}
+
+// requestETag returns a weak ETag for a GET request. It is derived from the
+// dataset and response versions and the canonical form of the request, so no
+// response needs to be buffered to compute it. It's weak since the same
+// content may be sent with different content encodings.
+func requestETag(r *http.Request) string {
+	h := fnv.New64a()
+	// Encode() sorts the parameters by key.
+	fmt.Fprintf(h, "%v;%x;%v?%v;%v", ResponseVersion, datasetVersion,
+		r.URL.Path, r.URL.Query().Encode(), r.Header.Get("Accept"))
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// NotModified reports whether the request's If-None-Match header matches
+// etag. Per RFC 7232, the comparison is weak, and * matches any etag, so
+// callers only check it for responses which exist, i.e. would be 200s.
+func NotModified(r *http.Request, etag string) bool {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteNotModified writes a 304 response for etag.
+func WriteNotModified(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-control", "public, max-age=120")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// etagResponseWriter drops the ETag header from non-200 responses, as only
+// successful responses are a function of the request, and from no-store
+// responses, such as results, which aren't a function of the dataset. The
+// remaining 200s are replaced with a 304 if they match the request's
+// If-None-Match, so that only requests which were routed, validated and rate
+// limited like any other are answered with a 304.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	etag        string
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *etagResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code != http.StatusOK || w.Header().Get("Cache-control") == "no-store" {
+		w.Header().Del("ETag")
+	} else if NotModified(w.r, w.etag) {
+		w.notModified = true
+		w.Header().Del("Content-Length")
+		WriteNotModified(w.ResponseWriter, w.etag)
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *etagResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *etagResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// etagMiddleware adds an ETag to GET and HEAD responses, and answers
+// conditional requests for them with a 304 once h has decided the response
+// is a 200, discarding its body.
+func etagMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" && r.Method != "HEAD" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			etag := requestETag(r)
+			w.Header().Set("ETag", etag)
+			h.ServeHTTP(&etagResponseWriter{ResponseWriter: w, r: r, etag: etag}, r)
+		})
+}
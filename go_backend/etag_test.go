@@ -0,0 +1,118 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		expected    bool
+	}{
+		{"", false},
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"xyz", W/"abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		r, _ := http.NewRequest("GET", "/zoom_buckets", nil)
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		if got := NotModified(r, `W/"abc"`); got != tt.expected {
+			t.Errorf("If-None-Match %v: expected %v, got %v", tt.ifNoneMatch, tt.expected, got)
+		}
+	}
+}
+
+func TestETagMiddleware(t *testing.T) {
+	calls := 0
+	h := etagMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.FormValue("fail") != "" {
+			http.Error(w, "Invalid", http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("stream") != "" {
+			w.Header().Set("Cache-control", "no-store")
+		}
+		w.Write([]byte("[]"))
+	}))
+
+	r, _ := http.NewRequest("GET", "/zoom_buckets?b=2&a=1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %v %q", w.Code, etag)
+	}
+
+	// Same request with parameters in a different order. The handler is
+	// still called, to decide whether the response would be a 200.
+	r, _ = http.NewRequest("GET", "/zoom_buckets?a=1&b=2", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || calls != 2 {
+		t.Errorf("Expected 304 without a body, got %v %q after %v calls", w.Code, w.Body, calls)
+	}
+
+	// Errors and no-store responses are never 304s, even for *.
+	for _, url := range []string{"/zoom_buckets?fail=1", "/results/stream?stream=1"} {
+		r, _ = http.NewRequest("GET", url, nil)
+		r.Header.Set("If-None-Match", "*")
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code == http.StatusNotModified || w.Header().Get("ETag") != "" {
+			t.Errorf("%v: expected no 304 and no ETag, got %v %q", url, w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestETagRoutes(t *testing.T) {
+	defer func() {
+		initState.finished = time.Time{}
+	}()
+	startInit()
+	finishInit()
+	h := newAPIHandler()
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/v1/zoom_buckets", http.StatusNotModified},
+		{"/v1/nowhere", http.StatusNotFound},
+		{"/v1/search?limit=0&q=banks", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		r.Header.Set("If-None-Match", "*")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%v: expected %v, got %v", tt.url, tt.status, w.Code)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"strings"
+
+	"github.com/fatih/structs"
+)
+
+// Presets of the 'fields' parameter, which selects the PollingPlace fields
+// included in polling place features. The location is the geometry, and
+// the type and minZoom properties are always included.
+const (
+	// FieldsMinimal is enough to show polling places on a map.
+	FieldsMinimal = "minimal"
+	// FieldsAddress adds what's needed to find the polling place.
+	FieldsAddress = "address"
+	// FieldsFull is every field, the default.
+	FieldsFull = "full"
+)
+
+var pollingPlaceFieldPresets = map[string][]string{
+	FieldsMinimal: {"PollingPlaceId", "PrettyPrintName", "DivisionName", "WheelchairAccess"},
+	FieldsAddress: {
+		"PollingPlaceId", "PrettyPrintName", "DivisionName", "WheelchairAccess",
+		"PremisesName", "Address1", "Address2", "Address3", "AddressSuburb",
+		"AddressStateAbbreviation", "Postcode", "EntrancesDescription",
+	},
+	FieldsFull: structs.Names(PollingPlace{}),
+}
+
+// pollingPlaceFields is a set of PollingPlace field names. The nil set is
+// every field.
+type pollingPlaceFields map[string]bool
+
+// pollingPlaceFieldValues returns the accepted values of the 'fields'
+// parameter: the presets, then the names of the fields.
+func pollingPlaceFieldValues() []string {
+	values := []string{FieldsMinimal, FieldsAddress, FieldsFull}
+	return append(values, pollingPlaceFieldPresets[FieldsFull]...)
+}
+
+// parsePollingPlaceFields parses a comma separated list of presets and field
+// names.
+func parsePollingPlaceFields(s string) (pollingPlaceFields, error) {
+	if s == "" || s == FieldsFull {
+		return nil, nil
+	}
+	fields := pollingPlaceFields{}
+	for _, f := range strings.Split(s, ",") {
+		if preset, ok := pollingPlaceFieldPresets[f]; ok {
+			for _, name := range preset {
+				fields[name] = true
+			}
+			continue
+		}
+		if _, ok := structs.New(PollingPlace{}).FieldOk(f); !ok {
+			return nil, newBadRequestError(CodeInvalidParameter, "fields", s,
+				"Expected fields to be %v, %v, %v or PollingPlace field names", FieldsMinimal, FieldsAddress, FieldsFull)
+		}
+		fields[f] = true
+	}
+	return fields, nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestPollingPlaceToFeatureFields(t *testing.T) {
+	p := PollingPlace{
+		DivisionName:     "Banks",
+		PrettyPrintName:  "Allawah",
+		PollingPlaceId:   31,
+		PremisesName:     "P J Ferry Hall",
+		Address1:         "cnr Bellevue Pde & Blakesley Rd",
+		AddressSuburb:    "ALLAWAH",
+		Lat:              -33.9767897,
+		Lng:              151.1148974,
+		WheelchairAccess: "Assisted",
+	}
+	tests := []struct {
+		fields   string
+		expected int
+	}{
+		// Each also has the type and minZoom properties.
+		{"", 29 + 2},
+		{FieldsFull, 29 + 2},
+		{FieldsMinimal, 4 + 2},
+		{FieldsAddress, 12 + 2},
+		{"minimal,Status", 5 + 2},
+	}
+	for _, tt := range tests {
+		fields, err := parsePollingPlaceFields(tt.fields)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.fields, err)
+		}
+		f := p.toFeature(fields)
+		if len(f.Properties) != tt.expected {
+			var names []string
+			for name := range f.Properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			t.Errorf("%v: expected %v properties, got %v", tt.fields, tt.expected, names)
+		}
+		if f.ID != "31" || f.Properties["type"] != TypePollingPlace {
+			t.Errorf("%v: expected polling place 31, got %v %v", tt.fields, f.ID, f.Properties["type"])
+		}
+	}
+	fields, _ := parsePollingPlaceFields(FieldsMinimal)
+	if wa := p.toFeature(fields).Properties["WheelchairAccess"]; wa != "Assisted" {
+		t.Errorf("Expected WheelchairAccess Assisted, got %v", wa)
+	}
+	if _, err := parsePollingPlaceFields("minimal,Phone"); err == nil {
+		t.Error("Expected unknown fields to be rejected")
+	}
+}
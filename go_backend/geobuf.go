@@ -0,0 +1,259 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// This file encodes feature collections as Geobuf
+// (https://github.com/mapbox/geobuf), a compact protobuf encoding of GeoJSON.
+// Like the vector tile encoder it only needs to write, so the encoding is
+// done by hand following geobuf.proto.
+
+// GeobufPrecision is the number of decimal places kept for coordinates,
+// matching the precision of the source shapefiles.
+const GeobufPrecision = 6
+
+// Geometry types, as defined in geobuf.proto.
+var geobufGeometryTypes = map[geojson.GeometryType]uint64{
+	geojson.GeometryPoint:           0,
+	geojson.GeometryMultiPoint:      1,
+	geojson.GeometryLineString:      2,
+	geojson.GeometryMultiLineString: 3,
+	geojson.GeometryPolygon:         4,
+	geojson.GeometryMultiPolygon:    5,
+}
+
+// geobufEncoder holds the keys table shared by all features of a Data
+// message.
+type geobufEncoder struct {
+	keys     []string
+	keyIndex map[string]uint32
+	e        float64
+}
+
+func newGeobufEncoder() *geobufEncoder {
+	return &geobufEncoder{
+		keyIndex: make(map[string]uint32),
+		e:        math.Pow10(GeobufPrecision),
+	}
+}
+
+func (ge *geobufEncoder) key(k string) uint32 {
+	i, ok := ge.keyIndex[k]
+	if !ok {
+		i = uint32(len(ge.keys))
+		ge.keys = append(ge.keys, k)
+		ge.keyIndex[k] = i
+	}
+	return i
+}
+
+func encodeGeobufValue(v interface{}) ([]byte, error) {
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		return appendBytesField(b, 1, []byte(v)), nil
+	case ElectorateID:
+		return appendBytesField(b, 1, []byte(v)), nil
+	case bool:
+		bv := uint64(0)
+		if v {
+			bv = 1
+		}
+		return appendVarintField(b, 5, bv), nil
+	case int:
+		return encodeGeobufInt(int64(v)), nil
+	case int64:
+		return encodeGeobufInt(v), nil
+	case float32:
+		return appendFixed64Field(b, 2, math.Float64bits(float64(v))), nil
+	case float64:
+		return appendFixed64Field(b, 2, math.Float64bits(v)), nil
+	}
+	// Anything else (e.g. centroids) is kept as JSON.
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return appendBytesField(b, 6, j), nil
+}
+
+func encodeGeobufInt(v int64) []byte {
+	if v >= 0 {
+		return appendVarintField(nil, 3, uint64(v))
+	}
+	return appendVarintField(nil, 4, uint64(-v))
+}
+
+// properties appends the values field and the properties field (pairs of
+// key index and value index) of a Feature message, in key order so that the
+// output is stable.
+func (ge *geobufEncoder) properties(b []byte, properties map[string]interface{}, valuesField, propertiesField int) ([]byte, error) {
+	if len(properties) == 0 {
+		return b, nil
+	}
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var indices []uint32
+	for i, k := range keys {
+		value, err := encodeGeobufValue(properties[k])
+		if err != nil {
+			return nil, err
+		}
+		b = appendBytesField(b, valuesField, value)
+		indices = append(indices, ge.key(k), uint32(i))
+	}
+	return appendPackedField(b, propertiesField, indices), nil
+}
+
+// line appends the delta encoded coordinates of a line. Closed lines (linear
+// rings) omit their last point.
+func (ge *geobufEncoder) line(coords []int64, line [][]float64, closed bool) []int64 {
+	n := len(line)
+	if closed {
+		n--
+	}
+	var sum [2]int64
+	for _, position := range line[:n] {
+		for j := range sum {
+			v := int64(math.Floor(position[j]*ge.e+0.5)) - sum[j]
+			coords = append(coords, v)
+			sum[j] += v
+		}
+	}
+	return coords
+}
+
+func (ge *geobufEncoder) geometry(g *geojson.Geometry) ([]byte, error) {
+	geomType, ok := geobufGeometryTypes[g.Type]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported geometry type %v", g.Type)
+	}
+	b := appendVarintField(nil, 1, geomType)
+	var lengths []uint32
+	var coords []int64
+	switch g.Type {
+	case geojson.GeometryPoint:
+		for _, v := range g.Point[:2] {
+			coords = append(coords, int64(math.Floor(v*ge.e+0.5)))
+		}
+	case geojson.GeometryMultiPoint:
+		coords = ge.line(coords, g.MultiPoint, false)
+	case geojson.GeometryLineString:
+		coords = ge.line(coords, g.LineString, false)
+	case geojson.GeometryMultiLineString, geojson.GeometryPolygon:
+		lines, closed := g.MultiLineString, false
+		if g.Type == geojson.GeometryPolygon {
+			lines, closed = g.Polygon, true
+		}
+		for _, line := range lines {
+			n := len(line)
+			if closed {
+				n--
+			}
+			lengths = append(lengths, uint32(n))
+			coords = ge.line(coords, line, closed)
+		}
+		if len(lines) == 1 {
+			lengths = nil
+		}
+	case geojson.GeometryMultiPolygon:
+		lengths = []uint32{uint32(len(g.MultiPolygon))}
+		for _, polygon := range g.MultiPolygon {
+			lengths = append(lengths, uint32(len(polygon)))
+			for _, ring := range polygon {
+				lengths = append(lengths, uint32(len(ring)-1))
+				coords = ge.line(coords, ring, true)
+			}
+		}
+		if len(g.MultiPolygon) == 1 && len(g.MultiPolygon[0]) == 1 {
+			lengths = nil
+		}
+	}
+	if lengths != nil {
+		b = appendPackedField(b, 2, lengths)
+	}
+	return appendPackedSint64Field(b, 3, coords), nil
+}
+
+// customProperties appends the bbox, which isn't part of the Geobuf schema,
+// as a custom property.
+func (ge *geobufEncoder) customProperties(b []byte, bbox []float64) ([]byte, error) {
+	if len(bbox) == 0 {
+		return b, nil
+	}
+	return ge.properties(b, map[string]interface{}{"bbox": bbox}, 13, 15)
+}
+
+func (ge *geobufEncoder) feature(f *geojson.Feature) ([]byte, error) {
+	var b []byte
+	if f.Geometry != nil {
+		geometry, err := ge.geometry(f.Geometry)
+		if err != nil {
+			return nil, err
+		}
+		b = appendBytesField(b, 1, geometry)
+	}
+	switch id := f.ID.(type) {
+	case nil:
+	case string:
+		b = appendBytesField(b, 11, []byte(id))
+	case int:
+		b = appendVarintField(b, 12, zigzag64(int64(id)))
+	default:
+		b = appendBytesField(b, 11, []byte(fmt.Sprint(id)))
+	}
+	b, err := ge.properties(b, f.Properties, 13, 14)
+	if err != nil {
+		return nil, err
+	}
+	return ge.customProperties(b, f.BoundingBox)
+}
+
+// encodeGeobuf returns the Geobuf Data message for a feature collection.
+func encodeGeobuf(fc *geojson.FeatureCollection) ([]byte, error) {
+	ge := newGeobufEncoder()
+	var collection []byte
+	for _, f := range fc.Features {
+		feature, err := ge.feature(f)
+		if err != nil {
+			return nil, err
+		}
+		collection = appendBytesField(collection, 1, feature)
+	}
+	collection, err := ge.customProperties(collection, fc.BoundingBox)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for _, k := range ge.keys {
+		data = appendBytesField(data, 1, []byte(k))
+	}
+	data = appendVarintField(data, 3, GeobufPrecision)
+	return appendBytesField(data, 4, collection), nil
+}
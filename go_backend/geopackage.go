@@ -0,0 +1,175 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	shp "github.com/jonas-p/go-shp"
+)
+
+// GeoPackage constants: the SQLite application ID "GPKG", the version of the
+// standard (1.2) and the date of the election as the time the content last
+// changed, so that exports don't depend on when they're made.
+const (
+	geoPackageApplicationID = 0x47504b47
+	geoPackageUserVersion   = 10200
+	geoPackageLastChange    = "2016-07-02T00:00:00.000Z"
+	wgs84SRSID              = 4326
+)
+
+// wgs84WKT is the definition of EPSG:4326 in gpkg_spatial_ref_sys.
+const wgs84WKT = `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,` +
+	`AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],` +
+	`UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]`
+
+// The tables every GeoPackage has, as defined by the standard.
+const (
+	gpkgSpatialRefSysSQL = `CREATE TABLE gpkg_spatial_ref_sys (
+  srs_name TEXT NOT NULL,
+  srs_id INTEGER NOT NULL PRIMARY KEY,
+  organization TEXT NOT NULL,
+  organization_coordsys_id INTEGER NOT NULL,
+  definition TEXT NOT NULL,
+  description TEXT
+)`
+	gpkgContentsSQL = `CREATE TABLE gpkg_contents (
+  table_name TEXT NOT NULL PRIMARY KEY,
+  data_type TEXT NOT NULL,
+  identifier TEXT UNIQUE,
+  description TEXT DEFAULT '',
+  last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+  min_x DOUBLE,
+  min_y DOUBLE,
+  max_x DOUBLE,
+  max_y DOUBLE,
+  srs_id INTEGER,
+  CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id)
+)`
+	gpkgGeometryColumnsSQL = `CREATE TABLE gpkg_geometry_columns (
+  table_name TEXT NOT NULL,
+  column_name TEXT NOT NULL,
+  geometry_type_name TEXT NOT NULL,
+  srs_id INTEGER NOT NULL,
+  z TINYINT NOT NULL,
+  m TINYINT NOT NULL,
+  CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name),
+  CONSTRAINT uk_gc_table_name UNIQUE (table_name),
+  CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name),
+  CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+)`
+)
+
+// geoPackageGeometry returns the polygons of eps as a GeoPackage geometry: a
+// header with the envelope, followed by a little endian WKB MultiPolygon.
+func geoPackageGeometry(eps []*ElectoratePolygon) []byte {
+	var b bytes.Buffer
+	box := polygonsBox(eps)
+	// Version 0, little endian with an XY envelope.
+	b.Write([]byte{'G', 'P', 0, 0x03})
+	binary.Write(&b, binary.LittleEndian, int32(wgs84SRSID))
+	binary.Write(&b, binary.LittleEndian, [4]float64{box.MinX, box.MaxX, box.MinY, box.MaxY})
+	// WKB MultiPolygon and Polygon types.
+	binary.Write(&b, binary.LittleEndian, struct {
+		ByteOrder byte
+		Type      uint32
+		N         uint32
+	}{1, 6, uint32(len(eps))})
+	for _, ep := range eps {
+		rings := polygonRings(ep)
+		binary.Write(&b, binary.LittleEndian, struct {
+			ByteOrder byte
+			Type      uint32
+			N         uint32
+		}{1, 3, uint32(len(rings))})
+		for _, ring := range rings {
+			binary.Write(&b, binary.LittleEndian, uint32(len(ring)))
+			binary.Write(&b, binary.LittleEndian, ring)
+		}
+	}
+	return b.Bytes()
+}
+
+// exportElectoratesGeoPackage writes a GeoPackage with a feature table name
+// of the electorates' MultiPolygons.
+func exportElectoratesGeoPackage(w io.Writer, name string, zoom ZoomLevel, ids []ElectorateID) error {
+	features := &sqliteTable{
+		Name: name,
+		SQL: `CREATE TABLE "` + name + `" (
+  fid INTEGER PRIMARY KEY,
+  geom MULTIPOLYGON,
+  id TEXT NOT NULL,
+  name TEXT NOT NULL,
+  state TEXT NOT NULL,
+  area_sqkm REAL NOT NULL
+)`,
+		RowIDColumn: 0,
+	}
+	var box shp.Box
+	for i, id := range ids {
+		e := electorates[id]
+		eps := e.polygons[zoom]
+		if i == 0 {
+			box = polygonsBox(eps)
+		} else {
+			box.Extend(polygonsBox(eps))
+		}
+		features.Rows = append(features.Rows, []interface{}{
+			int64(i + 1), geoPackageGeometry(eps), string(e.id), e.name, e.state, float64(e.areaSqkm),
+		})
+	}
+	tables := []*sqliteTable{
+		{
+			Name:        "gpkg_spatial_ref_sys",
+			SQL:         gpkgSpatialRefSysSQL,
+			RowIDColumn: 1,
+			Rows: [][]interface{}{
+				{"Undefined cartesian SRS", int64(-1), "NONE", int64(-1), "undefined", "undefined cartesian coordinate reference system"},
+				{"Undefined geographic SRS", int64(0), "NONE", int64(0), "undefined", "undefined geographic coordinate reference system"},
+				{"WGS 84 geodetic", int64(wgs84SRSID), "EPSG", int64(wgs84SRSID), wgs84WKT,
+					"longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid"},
+			},
+		},
+		{
+			Name:        "gpkg_contents",
+			SQL:         gpkgContentsSQL,
+			RowIDColumn: -1,
+			Rows: [][]interface{}{
+				{name, "features", name, "Electorates of the 2016 Australian federal election", geoPackageLastChange,
+					box.MinX, box.MinY, box.MaxX, box.MaxY, int64(wgs84SRSID)},
+			},
+			// table_name and identifier.
+			Indexes: [][]int{{0}, {2}},
+		},
+		{
+			Name:        "gpkg_geometry_columns",
+			SQL:         gpkgGeometryColumnsSQL,
+			RowIDColumn: -1,
+			Rows: [][]interface{}{
+				{name, "geom", "MULTIPOLYGON", int64(wgs84SRSID), int64(0), int64(0)},
+			},
+			// The primary key (table_name, column_name) and table_name.
+			Indexes: [][]int{{0, 1}, {0}},
+		},
+		features,
+	}
+	header := sqliteHeader{ApplicationID: geoPackageApplicationID, UserVersion: geoPackageUserVersion}
+	return writeSQLite(w, header, tables)
+}
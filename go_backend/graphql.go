@@ -0,0 +1,802 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	rtree "github.com/dhconnelly/rtreego"
+	"github.com/fatih/structs"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// MaxGraphQLBodyBytes is the largest POST body accepted by /graphql.
+const MaxGraphQLBodyBytes = 64 << 10
+
+// MaxGraphQLDetailedGeometries is the number of electorate geometries a
+// single GraphQL query may request above MaxZoomForAllElectorates, the
+// equivalent of ids=all not being allowed at those zoom levels.
+const MaxGraphQLDetailedGeometries = 10
+
+// MaxGraphQLGeometries is the number of electorate geometries a single
+// GraphQL query may request at any zoom, enough for every electorate once.
+const MaxGraphQLGeometries = 150
+
+// MaxGraphQLDepth is the deepest nesting of fields a query may have, e.g.
+// {electorates{pollingPlaces{id}}} is 3 deep. The schema has cycles, an
+// electorate's polling places have an electorate, so without a limit a short
+// query could do an unbounded amount of work.
+const MaxGraphQLDepth = 5
+
+// MaxGraphQLComplexity is the largest estimated number of fields a query may
+// resolve, see graphQLComplexity. Every field of every polling place is about
+// 220,000.
+const MaxGraphQLComplexity = 300000
+
+// GraphQLComplexityPerToken is the estimated number of fields resolved for
+// each rate limit token a query takes, on top of the first.
+const GraphQLComplexityPerToken = 10000
+
+// GraphQLGeometryComplexity is what a geometry at MaxZoomForAllElectorates
+// counts for in the complexity of a query, so that the geometry of every
+// electorate takes as many rate limit tokens as /electorates with ids=all.
+// Geometries count for less at lower zoom levels, and more at higher ones.
+const GraphQLGeometryComplexity = AllElectoratesCost * GraphQLComplexityPerToken / MaxGraphQLGeometries
+
+// maxGraphQLGeometryZoom is the zoom assumed for geometries whose zoom isn't
+// known, and the most detailed zoom they're charged for.
+const maxGraphQLGeometryZoom = 18
+
+// graphQLListSizes are rough sizes of the lists of objects in the schema, by
+// type and field, for estimating how many fields a query resolves.
+var graphQLListSizes = map[string]float64{
+	"Query.electorates":               150,
+	"Query.pollingPlaces":             7000,
+	"Electorate.pollingPlaces":        50,
+	"Electorate.pollingPlaceGroups":   20,
+	"PollingPlaceGroup.pollingPlaces": 10,
+}
+
+// graphQLRequest is the body of a POST to /graphql, and the parameters of a
+// GET.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+type graphQLContextKey int
+
+const graphQLBudgetKey graphQLContextKey = 0
+
+// graphQLBudget counts what a query has requested so far.
+type graphQLBudget struct {
+	geometries         int
+	detailedGeometries int
+}
+
+// graphQLFieldName returns the GraphQL name of a Go field, e.g. PremisesName
+// is premisesName.
+func graphQLFieldName(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// graphQLEnum returns an enum whose values are named as in the data.
+func graphQLEnum(name, description string, values []string) *graphql.Enum {
+	config := graphql.EnumValueConfigMap{}
+	for _, v := range values {
+		config[v] = &graphql.EnumValueConfig{Value: v}
+	}
+	return graphql.NewEnum(graphql.EnumConfig{Name: name, Description: description, Values: config})
+}
+
+var geoJSONScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "GeoJSON",
+	Description: "A GeoJSON geometry object.",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+})
+
+// pollingPlaceFilterArgs are the arguments of the polling place lists,
+// matching the filter parameters of /polling_places.
+var pollingPlaceFilterArgs = graphql.FieldConfigArgument{
+	"state": {
+		Type: graphql.NewList(graphql.NewNonNull(graphQLEnum("State", "", pollingPlaceStates))),
+	},
+	"wheelchairAccess": {
+		Type: graphql.NewList(graphql.NewNonNull(graphQLEnum("WheelchairAccess", "", pollingPlaceWheelchairAccess))),
+	},
+	"status": {
+		Type: graphql.NewList(graphql.NewNonNull(graphQLEnum("PollingPlaceStatus", "", pollingPlaceStatuses))),
+	},
+	"postcode": {
+		Type: graphql.NewList(graphql.NewNonNull(graphql.Int)),
+	},
+	"minOrdinaryVoteEstimate": {
+		Type: graphql.Int,
+	},
+	"bbox": {
+		Type:        graphql.String,
+		Description: "'minLat,minLng,maxLat,maxLng'",
+	},
+}
+
+// graphQLRect parses the bbox argument, which is nil if it isn't given.
+func graphQLRect(args map[string]interface{}) (*rtree.Rect, error) {
+	bbox, _ := args["bbox"].(string)
+	if bbox == "" {
+		return nil, nil
+	}
+	rect, err := ParseBboxToRect(bbox)
+	if err != nil {
+		return nil, newBadRequestError(CodeInvalidBbox, "bbox", bbox, "%v", err)
+	}
+	return rect, nil
+}
+
+// graphQLFilterParameters are the parameters of /polling_places given by
+// the list arguments of pollingPlaceFilterArgs.
+var graphQLFilterParameters = map[string]string{
+	"state":            "state",
+	"wheelchairAccess": "wheelchair_access",
+	"status":           "status",
+	"postcode":         "postcode",
+}
+
+// graphQLPollingPlaceFilter returns the filter given by
+// pollingPlaceFilterArgs, as the parameters of /polling_places.
+func graphQLPollingPlaceFilter(args map[string]interface{}) (*pollingPlaceFilter, error) {
+	params := url.Values{}
+	for arg, param := range graphQLFilterParameters {
+		values, _ := args[arg].([]interface{})
+		s := make([]string, len(values))
+		for i, v := range values {
+			s[i] = fmt.Sprint(v)
+		}
+		params.Set(param, strings.Join(s, ","))
+	}
+	if min, ok := args["minOrdinaryVoteEstimate"].(int); ok {
+		params.Set("min_ordinary_vote_estimate", strconv.Itoa(min))
+	}
+	bbox, _ := args["bbox"].(string)
+	params.Set("bbox", bbox)
+	return newPollingPlaceFilter(params.Get)
+}
+
+// filterPollingPlaces returns the indices of the polling places matching
+// filter out of indices.
+func filterPollingPlaces(indices []int, filter *pollingPlaceFilter) []int {
+	var matching []int
+	for _, i := range indices {
+		if filter.matches(&pollingPlaces[i]) {
+			matching = append(matching, i)
+		}
+	}
+	return matching
+}
+
+// electoratePollingPlaces returns the indices of the polling places of e.
+func electoratePollingPlaces(e *Electorate) []int {
+	var indices []int
+	for _, ep := range e.polygons[highestZoomLevel] {
+		indices = append(indices, ep.pollingPlaces...)
+	}
+	return indices
+}
+
+// Resolvers of polling places have the index of the polling place in
+// pollingPlaces as their source, electorates have the *Electorate and polling
+// place groups the *pollingPlaceGroup.
+var (
+	pollingPlaceType      *graphql.Object
+	pollingPlaceGroupType *graphql.Object
+	electorateType        *graphql.Object
+)
+
+func init() {
+	pollingPlaceType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PollingPlace",
+		Description: "A polling place, with the fields of the AEC data.",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			fields := graphql.Fields{
+				"id": {
+					Type: graphql.NewNonNull(graphql.ID),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return pollingPlaces[p.Source.(int)].PollingPlaceId, nil
+					},
+				},
+				"minZoom": {
+					Type:        graphql.NewNonNull(graphql.Int),
+					Description: "The zoom level from which the polling place isn't clustered.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return pollingPlaceMinZoom[p.Source.(int)], nil
+					},
+				},
+				"electorate": {
+					Type:        electorateType,
+					Description: "The electorate the polling place is listed under.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						id := ElectorateID(strings.ToLower(pollingPlaces[p.Source.(int)].DivisionName))
+						if e := electorates[id]; e != nil {
+							return e, nil
+						}
+						return nil, nil
+					},
+				},
+			}
+			for _, f := range structs.Fields(PollingPlace{}) {
+				var t graphql.Output
+				switch f.Kind() {
+				case reflect.Int:
+					t = graphql.Int
+				case reflect.Float64:
+					t = graphql.Float
+				default:
+					t = graphql.String
+				}
+				name := f.Name()
+				fields[graphQLFieldName(name)] = &graphql.Field{
+					Type: graphql.NewNonNull(t),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return structs.New(&pollingPlaces[p.Source.(int)]).Field(name).Value(), nil
+					},
+				}
+			}
+			return fields
+		}),
+	})
+	pollingPlaceGroupType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "PollingPlaceGroup",
+		Description: "A cluster of polling places, shown instead of them at minZoom.",
+		Fields: graphql.Fields{
+			"id": {
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*pollingPlaceGroup).ID(), nil
+				},
+			},
+			"lat": {
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*pollingPlaceGroup).Lat, nil
+				},
+			},
+			"lng": {
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*pollingPlaceGroup).Lng, nil
+				},
+			},
+			"minZoom": {
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*pollingPlaceGroup).minZoom, nil
+				},
+			},
+			"count": {
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return len(p.Source.(*pollingPlaceGroup).pollingPlaceIndices), nil
+				},
+			},
+			"pollingPlaces": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pollingPlaceType))),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*pollingPlaceGroup).pollingPlaceIndices, nil
+				},
+			},
+		},
+	})
+	electorateType = graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Electorate",
+		Description: "A federal electoral division.",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": {
+					Type: graphql.NewNonNull(graphql.ID),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return string(p.Source.(*Electorate).id), nil
+					},
+				},
+				"name": {
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*Electorate).name, nil
+					},
+				},
+				"state": {
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*Electorate).state, nil
+					},
+				},
+				"areaSqKm": {
+					Type: graphql.NewNonNull(graphql.Float),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(*Electorate).areaSqkm, nil
+					},
+				},
+				"bbox": {
+					Type:        graphql.NewList(graphql.NewNonNull(graphql.Float)),
+					Description: "[minLng, minLat, maxLng, maxLat], as in GeoJSON.",
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return electoratesBoundingBox([]ElectorateID{p.Source.(*Electorate).id}), nil
+					},
+				},
+				"geometry": {
+					Type: geoJSONScalar,
+					Description: fmt.Sprintf("The MultiPolygon of the electorate, at most %v per query, and %v above zoom %v.",
+						MaxGraphQLGeometries, MaxGraphQLDetailedGeometries, MaxZoomForAllElectorates),
+					Args: graphql.FieldConfigArgument{
+						"zoom": {Type: graphql.NewNonNull(graphql.Int)},
+					},
+					Resolve: resolveElectorateGeometry,
+				},
+				"pollingPlaces": {
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pollingPlaceType))),
+					Args: pollingPlaceFilterArgs,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						filter, err := graphQLPollingPlaceFilter(p.Args)
+						if err != nil {
+							return nil, err
+						}
+						return filterPollingPlaces(electoratePollingPlaces(p.Source.(*Electorate)), filter), nil
+					},
+				},
+				"pollingPlaceGroups": {
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pollingPlaceGroupType))),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						e := p.Source.(*Electorate)
+						groups := make([]*pollingPlaceGroup, len(e.pplaceGrps))
+						for i := range e.pplaceGrps {
+							groups[i] = &e.pplaceGrps[i]
+						}
+						return groups, nil
+					},
+				},
+			}
+		}),
+	})
+}
+
+func resolveElectorateGeometry(p graphql.ResolveParams) (interface{}, error) {
+	zoom := p.Args["zoom"].(int)
+	budget, _ := p.Context.Value(graphQLBudgetKey).(*graphQLBudget)
+	if budget != nil {
+		budget.geometries++
+		if budget.geometries > MaxGraphQLGeometries {
+			return nil, newBadRequestError(CodeQueryTooComplex, "query", "",
+				"At most %v geometries may be requested", MaxGraphQLGeometries)
+		}
+		if zoom > MaxZoomForAllElectorates {
+			budget.detailedGeometries++
+			if budget.detailedGeometries > MaxGraphQLDetailedGeometries {
+				return nil, newBadRequestError(CodeAllNotAllowedAtZoom, "zoom", fmt.Sprint(zoom),
+					"At most %v geometries may be requested above zoom %v", MaxGraphQLDetailedGeometries, MaxZoomForAllElectorates)
+			}
+		}
+	}
+	e := p.Source.(*Electorate)
+	return ShpPolygonToGeojsonFeature(e.polygons[chooseBestZoomBucket(zoom)]).Geometry, nil
+}
+
+type byElectorateID []*Electorate
+
+func (e byElectorateID) Len() int           { return len(e) }
+func (e byElectorateID) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byElectorateID) Less(i, j int) bool { return e[i].id < e[j].id }
+
+// resolveElectorates returns the electorates with the given ids, in the
+// bbox, or else every electorate, ordered by ID.
+func resolveElectorates(p graphql.ResolveParams) (interface{}, error) {
+	var result []*Electorate
+	rect, err := graphQLRect(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if ids, _ := p.Args["ids"].([]interface{}); len(ids) > 0 {
+		for _, id := range ids {
+			e := electorates[ElectorateID(id.(string))]
+			if e == nil {
+				return nil, newUnknownElectorateError(id.(string))
+			}
+			result = append(result, e)
+		}
+	} else if rect != nil {
+		for _, spatial := range searchElectorates("graphql", rect) {
+			if e, ok := spatial.(*Electorate); ok {
+				result = append(result, e)
+			}
+		}
+	} else {
+		for _, e := range electorates {
+			result = append(result, e)
+		}
+	}
+	sort.Sort(byElectorateID(result))
+	return result, nil
+}
+
+// resolvePollingPlaces returns the polling places of the electorate argument,
+// or of every electorate, matching the filter arguments.
+func resolvePollingPlaces(p graphql.ResolveParams) (interface{}, error) {
+	filter, err := graphQLPollingPlaceFilter(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	if id, _ := p.Args["electorate"].(string); id != "" {
+		e := electorates[ElectorateID(id)]
+		if e == nil {
+			return nil, newUnknownElectorateError(id)
+		}
+		return filterPollingPlaces(electoratePollingPlaces(e), filter), nil
+	}
+	indices := make([]int, len(pollingPlaces))
+	for i := range pollingPlaces {
+		indices[i] = i
+	}
+	return filterPollingPlaces(indices, filter), nil
+}
+
+func newGraphQLSchema() (graphql.Schema, error) {
+	electorateArgs := graphql.FieldConfigArgument{
+		"ids":  {Type: graphql.NewList(graphql.NewNonNull(graphql.ID))},
+		"bbox": pollingPlaceFilterArgs["bbox"],
+	}
+	pollingPlacesArgs := graphql.FieldConfigArgument{
+		"electorate": {Type: graphql.ID},
+	}
+	for name, arg := range pollingPlaceFilterArgs {
+		pollingPlacesArgs[name] = arg
+	}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"electorate": {
+				Type: electorateType,
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Args["id"].(string)
+					e := electorates[ElectorateID(id)]
+					if e == nil {
+						return nil, newUnknownElectorateError(id)
+					}
+					return e, nil
+				},
+			},
+			"electorates": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(electorateType))),
+				Description: "The electorates with the given ids, or in the bbox, or else every electorate.",
+				Args:        electorateArgs,
+				Resolve:     resolveElectorates,
+			},
+			"locate": {
+				Type:        electorateType,
+				Description: "The electorate containing a location, if any.",
+				Args: graphql.FieldConfigArgument{
+					"lat": {Type: graphql.NewNonNull(graphql.Float)},
+					"lng": {Type: graphql.NewNonNull(graphql.Float)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					if e := locateElectorate(p.Args["lng"].(float64), p.Args["lat"].(float64)); e != nil {
+						return e, nil
+					}
+					return nil, nil
+				},
+			},
+			"pollingPlace": {
+				Type: pollingPlaceType,
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id := p.Args["id"].(int)
+					for i := range pollingPlaces {
+						if pollingPlaces[i].PollingPlaceId == id {
+							return i, nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"pollingPlaces": {
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pollingPlaceType))),
+				Description: "The polling places of an electorate, or anywhere, matching the filter arguments.",
+				Args:        pollingPlacesArgs,
+				Resolve:     resolvePollingPlaces,
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+var graphQLSchema graphql.Schema
+
+func init() {
+	var err error
+	graphQLSchema, err = newGraphQLSchema()
+	if err != nil {
+		panic(err)
+	}
+}
+
+// parseGraphQLRequest reads the query of a GET or POST to /graphql. POST
+// bodies are either JSON or, with the application/graphql content type, the
+// query itself.
+func parseGraphQLRequest(r *http.Request) (*graphQLRequest, error) {
+	var req graphQLRequest
+	if r.Method != "POST" {
+		req.Query = r.FormValue("query")
+		req.OperationName = r.FormValue("operationName")
+		if variables := r.FormValue("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return nil, newBadRequestError(CodeInvalidParameter, "variables", variables, "Invalid variables: %v", err)
+			}
+		}
+	} else {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxGraphQLBodyBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > MaxGraphQLBodyBytes {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "The body is larger than %v bytes", MaxGraphQLBodyBytes)
+		}
+		if strings.HasPrefix(r.Header.Get("Content-type"), "application/graphql") {
+			req.Query = string(body)
+		} else if err := json.Unmarshal(body, &req); err != nil {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "Invalid GraphQL request: %v", err)
+		}
+	}
+	if req.Query == "" {
+		return nil, newBadRequestError(CodeMissingParameter, "query", "", "query parameter required")
+	}
+	if err := checkGraphQLComplexity(&req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// graphQLComplexityWalker walks the selections of a query document.
+type graphQLComplexityWalker struct {
+	variables map[string]interface{}
+	fragments map[string]*ast.FragmentDefinition
+	// visiting are the fragments being walked, to stop at cycles, which
+	// are only rejected later by validation.
+	visiting map[string]bool
+	// geometries is the estimated number of electorate geometries.
+	geometries float64
+}
+
+// zoom returns the zoom argument of field, or maxGraphQLGeometryZoom if it
+// isn't known, e.g. the variable is missing.
+func (w *graphQLComplexityWalker) zoom(field *ast.Field) int {
+	zoom := maxGraphQLGeometryZoom
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "zoom" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if z, err := strconv.Atoi(v.Value); err == nil {
+				zoom = z
+			}
+		case *ast.Variable:
+			if z, ok := w.variables[v.Name.Value].(float64); ok {
+				zoom = int(z)
+			}
+		}
+	}
+	if zoom < 0 {
+		return 0
+	}
+	if zoom > maxGraphQLGeometryZoom {
+		return maxGraphQLGeometryZoom
+	}
+	return zoom
+}
+
+// graphQLGeometryComplexity is what a geometry at zoom counts for.
+func graphQLGeometryComplexity(zoom int) float64 {
+	return GraphQLGeometryComplexity * float64(zoom+1) / (MaxZoomForAllElectorates + 1)
+}
+
+// walk returns the depth of set, and the estimated number of fields it
+// resolves when it's selected on count objects of type t. t is nil where the
+// type isn't known, in which case lists are assumed to have one object.
+func (w *graphQLComplexityWalker) walk(t *graphql.Object, set *ast.SelectionSet, count float64) (int, float64) {
+	if set == nil {
+		return 0, 0
+	}
+	var depth int
+	var complexity float64
+	add := func(d int, c float64) {
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			name := s.Name.Value
+			// Introspection is bounded by the size of the schema.
+			if strings.HasPrefix(name, "__") {
+				add(1, count)
+				continue
+			}
+			// Geometries are much larger than other fields.
+			if t != nil && t.Name() == "Electorate" && name == "geometry" {
+				w.geometries += count
+				add(1, count*graphQLGeometryComplexity(w.zoom(s)))
+				continue
+			}
+			var fieldType *graphql.Object
+			size := 1.0
+			if t != nil {
+				if field := t.Fields()[name]; field != nil {
+					fieldType, _ = graphQLNamedType(field.Type).(*graphql.Object)
+					if n, ok := graphQLListSizes[t.Name()+"."+name]; ok {
+						size = n
+					}
+				}
+			}
+			d, c := w.walk(fieldType, s.SelectionSet, count*size)
+			add(d+1, count+c)
+		case *ast.InlineFragment:
+			add(w.walk(t, s.SelectionSet, count))
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment := w.fragments[name]
+			if fragment == nil || w.visiting[name] {
+				continue
+			}
+			w.visiting[name] = true
+			add(w.walk(t, fragment.SelectionSet, count))
+			delete(w.visiting, name)
+		}
+	}
+	return depth, complexity
+}
+
+// graphQLNamedType returns t without its list and non-null wrappers.
+func graphQLNamedType(t graphql.Type) graphql.Type {
+	for {
+		switch wrapper := t.(type) {
+		case *graphql.List:
+			t = wrapper.OfType
+		case *graphql.NonNull:
+			t = wrapper.OfType
+		default:
+			return t
+		}
+	}
+}
+
+// graphQLComplexity returns the depth of the query of req, an estimate of the
+// number of fields it resolves, and of the number of geometries. Each field
+// counts once for each object it's selected on, with lists having the sizes
+// in graphQLListSizes, and each geometry counts as graphQLGeometryComplexity
+// fields. Aliases of a field count separately, as they're resolved
+// separately. Queries which don't parse have no complexity, executeGraphQL
+// reports their errors.
+func graphQLComplexity(req *graphQLRequest) (int, float64, float64) {
+	doc, err := parser.Parse(parser.ParseParams{Source: req.Query})
+	if err != nil {
+		return 0, 0, 0
+	}
+	w := &graphQLComplexityWalker{
+		variables: req.Variables,
+		fragments: map[string]*ast.FragmentDefinition{},
+		visiting:  map[string]bool{},
+	}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			w.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	var depth int
+	var complexity float64
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if req.OperationName != "" && (operation.Name == nil || operation.Name.Value != req.OperationName) {
+			continue
+		}
+		var t *graphql.Object
+		if operation.Operation == ast.OperationTypeQuery {
+			t = graphQLSchema.QueryType()
+		}
+		d, c := w.walk(t, operation.SelectionSet, 1)
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+	return depth, complexity, w.geometries
+}
+
+// checkGraphQLComplexity returns an error if the query of req is deeper than
+// MaxGraphQLDepth, more complex than MaxGraphQLComplexity, or requests more
+// than MaxGraphQLGeometries geometries.
+func checkGraphQLComplexity(req *graphQLRequest) error {
+	depth, complexity, geometries := graphQLComplexity(req)
+	if depth > MaxGraphQLDepth {
+		return newBadRequestError(CodeQueryTooComplex, "query", "",
+			"The query is nested %v fields deep, the maximum is %v", depth, MaxGraphQLDepth)
+	}
+	if complexity > MaxGraphQLComplexity {
+		return newBadRequestError(CodeQueryTooComplex, "query", "",
+			"The query resolves an estimated %.0f fields, the maximum is %v", complexity, MaxGraphQLComplexity)
+	}
+	if geometries > MaxGraphQLGeometries {
+		return newBadRequestError(CodeQueryTooComplex, "query", "",
+			"The query requests an estimated %.0f geometries, the maximum is %v", geometries, MaxGraphQLGeometries)
+	}
+	return nil
+}
+
+// graphQLCost is the rate limit cost of a GraphQL request, which grows with
+// the estimated complexity of its query. The body of POSTs is read, and
+// replaced for the handler.
+func graphQLCost(r *http.Request) float64 {
+	if r.Method == "POST" {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxGraphQLBodyBytes+1))
+		if err != nil {
+			return 1
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		defer func() { r.Body = ioutil.NopCloser(bytes.NewReader(body)) }()
+	}
+	req, err := parseGraphQLRequest(r)
+	if err != nil {
+		return 1
+	}
+	_, complexity, _ := graphQLComplexity(req)
+	return 1 + math.Floor(complexity/GraphQLComplexityPerToken)
+}
+
+// executeGraphQL runs a query against graphQLSchema. Errors from resolvers
+// which are APIErrors have their code in their extensions.
+func executeGraphQL(ctx context.Context, req *graphQLRequest) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:         graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(ctx, graphQLBudgetKey, &graphQLBudget{}),
+	})
+}
@@ -0,0 +1,234 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphQLQuery(t *testing.T) {
+	saved := electorates
+	defer func() { electorates = saved }()
+	// Allawah and Beverly Hills South, in Banks.
+	electorates = map[ElectorateID]*Electorate{
+		"banks": {
+			id:    "banks",
+			name:  "Banks",
+			state: "NSW",
+			polygons: map[ZoomLevel][]*ElectoratePolygon{
+				highestZoomLevel: {{pollingPlaces: []int{0, 3}}},
+			},
+		},
+	}
+	query := `{"query": "query($id: ID!) {electorate(id: $id) {name pollingPlaces(wheelchairAccess: [Full]) {id premisesName}}}",
+		"variables": {"id": "banks"}}`
+	w := httptest.NewRecorder()
+	graphQLQuery(w, httptest.NewRequest("POST", "/graphql", strings.NewReader(query)))
+	var response struct {
+		Data struct {
+			Electorate struct {
+				Name          string
+				PollingPlaces []struct {
+					ID           string
+					PremisesName string
+				}
+			}
+		}
+		Errors []interface{}
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Errors) != 0 {
+		t.Fatalf("Unexpected errors %v", response.Errors)
+	}
+	e := response.Data.Electorate
+	if e.Name != "Banks" || len(e.PollingPlaces) != 1 || e.PollingPlaces[0].ID != "79612" ||
+		e.PollingPlaces[0].PremisesName != "Beverly Hills Public School" {
+		t.Errorf("Unexpected electorate %+v", e)
+	}
+}
+
+func TestGraphQLPollingPlaceElectorate(t *testing.T) {
+	saved := electorates
+	defer func() { electorates = saved }()
+	// The electorate is the one Beverly Hills South is listed under, there
+	// are no polygons to locate it in.
+	electorates = map[ElectorateID]*Electorate{"banks": {id: "banks", name: "Banks"}}
+	w := httptest.NewRecorder()
+	graphQLQuery(w, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{pollingPlace(id: 79612) {electorate {name}}}`), nil))
+	var response struct {
+		Data struct {
+			PollingPlace struct {
+				Electorate struct {
+					Name string
+				}
+			}
+		}
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if name := response.Data.PollingPlace.Electorate.Name; name != "Banks" {
+		t.Errorf("Expected Banks, got %q", name)
+	}
+}
+
+func TestGraphQLErrorCode(t *testing.T) {
+	w := httptest.NewRecorder()
+	graphQLQuery(w, httptest.NewRequest("GET", `/graphql?query={electorate(id:"nowhere"){name}}`, nil))
+	var response struct {
+		Errors []struct {
+			Extensions map[string]string
+		}
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Errors) != 1 || response.Errors[0].Extensions["code"] != CodeUnknownElectorate {
+		t.Errorf("Expected an %v error, got %+v", CodeUnknownElectorate, response.Errors)
+	}
+}
+
+func TestGraphQLMissingQuery(t *testing.T) {
+	w := httptest.NewRecorder()
+	graphQLQuery(w, httptest.NewRequest("GET", "/graphql", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected 400, got %v", w.Code)
+	}
+}
+
+func TestGraphQLComplexity(t *testing.T) {
+	tests := []struct {
+		query      string
+		variables  map[string]interface{}
+		depth      int
+		complexity float64
+		geometries float64
+	}{
+		{`{electorate(id: "banks") {name}}`, nil, 2, 2, 0},
+		{`{electorates {pollingPlaces {id}}}`, nil, 3, 1 + 150 + 7500, 0},
+		{`{electorates {...places}} fragment places on Electorate {pollingPlaces {id}}`, nil, 3, 1 + 150 + 7500, 0},
+		// Cycles of fragments are rejected by validation.
+		{`{electorates {...a}} fragment a on Electorate {...a}`, nil, 1, 1, 0},
+		{`{__schema {types {fields {type {ofType {name}}}}}}`, nil, 1, 1, 0},
+		{`{`, nil, 0, 0, 0},
+		// Geometries are weighted by their zoom, from arguments or
+		// variables, and by the electorates they're selected on.
+		{`{electorates {geometry(zoom: 8)}}`, nil, 2, 1 + 150*GraphQLGeometryComplexity, 150},
+		{`query($z: Int!) {electorate(id: "banks") {geometry(zoom: $z)}}`, map[string]interface{}{"z": 2.0},
+			2, 1 + graphQLGeometryComplexity(2), 1},
+		{`query($z: Int!) {electorate(id: "banks") {geometry(zoom: $z)}}`, nil,
+			2, 1 + graphQLGeometryComplexity(maxGraphQLGeometryZoom), 1},
+		// Aliases count separately.
+		{`{a: electorates {geometry(zoom: 0)} b: electorates {geometry(zoom: 0)}}`, nil,
+			2, 2 + 300*graphQLGeometryComplexity(0), 300},
+	}
+	for _, tt := range tests {
+		depth, complexity, geometries := graphQLComplexity(&graphQLRequest{Query: tt.query, Variables: tt.variables})
+		if depth != tt.depth || complexity != tt.complexity || geometries != tt.geometries {
+			t.Errorf("%v: expected depth %v, complexity %v and %v geometries, got %v, %v and %v",
+				tt.query, tt.depth, tt.complexity, tt.geometries, depth, complexity, geometries)
+		}
+	}
+}
+
+func TestGraphQLTooComplex(t *testing.T) {
+	queries := []string{
+		// Too deep.
+		`{electorates{pollingPlaces{electorate{pollingPlaces{electorate{pollingPlaces{id}}}}}}}`,
+		// Shallow enough, but with too many polling places.
+		`{electorates{pollingPlaces{electorate{pollingPlaces{id}}}}}`,
+		// Every electorate twice, through aliases.
+		`{a: electorates{geometry(zoom: 1)} b: electorates{geometry(zoom: 1)}}`,
+	}
+	// Many aliases of detailed geometries.
+	var aliases []string
+	for i := 0; i < 900; i++ {
+		aliases = append(aliases, fmt.Sprintf("e%v: electorates{geometry(zoom: 8)}", i))
+	}
+	queries = append(queries, "{"+strings.Join(aliases, " ")+"}")
+	for _, query := range queries {
+		w := httptest.NewRecorder()
+		graphQLQuery(w, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil))
+		var response APIError
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != 400 || response.Code != CodeQueryTooComplex {
+			t.Errorf("%v: expected a 400 %v error, got %v %+v", query, CodeQueryTooComplex, w.Code, response)
+		}
+	}
+}
+
+func TestGraphQLCost(t *testing.T) {
+	query := `{"query": "{pollingPlaces {id premisesName lat lng}}"}`
+	r := httptest.NewRequest("POST", "/graphql", strings.NewReader(query))
+	if cost := graphQLCost(r); cost != 3 {
+		t.Errorf("Expected a cost of 3, got %v", cost)
+	}
+	// The handler can still read the body.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil || string(body) != query {
+		t.Errorf("Expected the body to be replaced, got %q: %v", body, err)
+	}
+	r = httptest.NewRequest("GET", `/graphql?query={electorate(id:"banks"){name}}`, nil)
+	if cost := graphQLCost(r); cost != 1 {
+		t.Errorf("Expected a cost of 1, got %v", cost)
+	}
+	// Every geometry costs as much as /electorates with ids=all.
+	r = httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{electorates{geometry(zoom: 8)}}`), nil)
+	if cost := graphQLCost(r); cost != AllElectoratesCost {
+		t.Errorf("Expected a cost of %v, got %v", AllElectoratesCost, cost)
+	}
+}
+
+func TestGraphQLRoutes(t *testing.T) {
+	saved := electorates
+	defer func() {
+		electorates = saved
+		initState.finished = time.Time{}
+	}()
+	electorates = map[ElectorateID]*Electorate{"banks": {id: "banks", name: "Banks", state: "NSW"}}
+	startInit()
+	finishInit()
+	h := newAPIHandler()
+	// Both methods reach their own route, in each version.
+	query := `{electorate(id:"banks"){name}}`
+	for _, prefix := range []string{"", "/v1"} {
+		for _, r := range []*http.Request{
+			httptest.NewRequest("GET", prefix+"/graphql?query="+url.QueryEscape(query), nil),
+			httptest.NewRequest("POST", prefix+"/graphql", strings.NewReader(`{"query": `+strconv.Quote(query)+`}`)),
+		} {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"Banks"`) {
+				t.Errorf("%v %v: expected Banks, got %v %v", r.Method, r.URL, w.Code, w.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	shp "github.com/jonas-p/go-shp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcServer implements the ElectionService of election.proto with the
+// same functions as the HTTP API.
+type grpcServer struct {
+	UnimplementedElectionServiceServer
+}
+
+// NewGRPCServer returns a gRPC server with the ElectionService. Like the
+// HTTP API, calls fail with Unavailable until the dataset is loaded.
+func NewGRPCServer() *grpc.Server {
+	s := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if !isReady() {
+				return nil, grpcNotReadyError()
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if !isReady() {
+				return grpcNotReadyError()
+			}
+			return handler(srv, ss)
+		}),
+	)
+	RegisterElectionServiceServer(s, &grpcServer{})
+	return s
+}
+
+func grpcNotReadyError() error {
+	return status.Errorf(codes.Unavailable, "%v: The dataset is still loading", CodeNotReady)
+}
+
+// grpcError converts an error of the HTTP API to a gRPC status, keeping the
+// code of APIErrors in the message.
+func grpcError(err error) error {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		return status.Errorf(codes.Internal, "%v: %v", CodeInternalError, err)
+	}
+	code := codes.Internal
+	switch apiErr.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusNotFound:
+		code = codes.NotFound
+	}
+	return status.Errorf(code, "%v: %v", apiErr.Code, apiErr.Message)
+}
+
+func electorateSummary(e *Electorate) *ElectorateSummary {
+	return &ElectorateSummary{
+		ElectorateId: string(e.id),
+		Name:         e.name,
+		State:        e.state,
+		AreaSqkm:     float64(e.areaSqkm),
+	}
+}
+
+func locateElectorateResponse(req *LocateElectorateRequest) *LocateElectorateResponse {
+	response := &LocateElectorateResponse{Id: req.Id}
+	if e := locateElectorate(req.Lng, req.Lat); e != nil {
+		response.Found = true
+		response.Electorate = electorateSummary(e)
+	}
+	return response
+}
+
+func (s *grpcServer) LocateElectorate(ctx context.Context, req *LocateElectorateRequest) (*LocateElectorateResponse, error) {
+	return locateElectorateResponse(req), nil
+}
+
+func (s *grpcServer) LocateElectorates(stream ElectionService_LocateElectoratesServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(locateElectorateResponse(req)); err != nil {
+			return err
+		}
+	}
+}
+
+func lngLat(coords []float64) *LngLat {
+	return &LngLat{Lng: coords[0], Lat: coords[1]}
+}
+
+func (s *grpcServer) GetElectorateGeometry(ctx context.Context, req *GetElectorateGeometryRequest) (*ElectorateGeometry, error) {
+	zoom := chooseBestZoomBucket(int(req.Zoom))
+	f, err := electorateToGeoJsonFeature(ElectorateID(req.ElectorateId), zoom)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	e := electorates[ElectorateID(req.ElectorateId)]
+	g := &ElectorateGeometry{
+		Electorate: electorateSummary(e),
+		Bbox: &BoundingBox{
+			MinLng: e.bbox.MinX,
+			MinLat: e.bbox.MinY,
+			MaxLng: e.bbox.MaxX,
+			MaxLat: e.bbox.MaxY,
+		},
+	}
+	for i, polygon := range f.Geometry.MultiPolygon {
+		pg := &PolygonGeometry{
+			Centroid: &LngLat{Lng: float64(e.polygons[zoom][i].centLong), Lat: float64(e.polygons[zoom][i].centLat)},
+		}
+		for _, ring := range polygon {
+			lr := &LinearRing{Points: make([]*LngLat, len(ring))}
+			for j, coords := range ring {
+				lr.Points[j] = lngLat(coords)
+			}
+			pg.Rings = append(pg.Rings, lr)
+		}
+		g.Polygons = append(g.Polygons, pg)
+	}
+	return g, nil
+}
+
+func pollingPlaceRecord(i int) *PollingPlaceRecord {
+	p := &pollingPlaces[i]
+	return &PollingPlaceRecord{
+		StateCode:                        int32(p.StateCode),
+		StateAbbreviation:                p.StateAbbreviation,
+		DivisionName:                     p.DivisionName,
+		DivisionId:                       int32(p.DivisionId),
+		DivisionCode:                     int32(p.DivisionCode),
+		PrettyPrintName:                  p.PrettyPrintName,
+		PollingPlaceId:                   int32(p.PollingPlaceId),
+		Status:                           p.Status,
+		PremisesName:                     p.PremisesName,
+		Address1:                         p.Address1,
+		Address2:                         p.Address2,
+		Address3:                         p.Address3,
+		AddressSuburb:                    p.AddressSuburb,
+		AddressStateAbbreviation:         p.AddressStateAbbreviation,
+		Postcode:                         int32(p.Postcode),
+		AdvPremisesName:                  p.AdvPremisesName,
+		AdvAddress:                       p.AdvAddress,
+		AdvLocality:                      p.AdvLocality,
+		AdviceBoothLocation:              p.AdviceBoothLocation,
+		AdviceGateAccess:                 p.AdviceGateAccess,
+		EntrancesDescription:             p.EntrancesDescription,
+		Lat:                              p.Lat,
+		Lng:                              p.Lng,
+		CensusCollectionDistrict:         int32(p.CensusCollectionDistrict),
+		WheelchairAccess:                 p.WheelchairAccess,
+		OrdinaryVoteEstimate:             int32(p.OrdinaryVoteEstimate),
+		DeclarationVoteEstimate:          int32(p.DeclarationVoteEstimate),
+		NumberOrdinaryIssuingOfficers:    int32(p.NumberOrdinaryIssuingOfficers),
+		NumberDeclarationIssuingOfficers: int32(p.NumberDeclarationIssuingOfficers),
+		MinZoom:                          int32(pollingPlaceMinZoom[i]),
+	}
+}
+
+// bboxString returns b as the 'lat,lng,lat,lng' of the bbox parameter, for
+// error messages.
+func bboxString(b *BoundingBox) string {
+	if b == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v,%v,%v,%v", b.MinLat, b.MinLng, b.MaxLat, b.MaxLng)
+}
+
+func (s *grpcServer) QueryViewport(ctx context.Context, req *QueryViewportRequest) (*QueryViewportResponse, error) {
+	if req.Bbox == nil {
+		return nil, grpcError(newBadRequestError(CodeInvalidBbox, "bbox", "", "bbox required"))
+	}
+	rect, err := BboxToRect(&shp.Box{MinX: req.Bbox.MinLng, MinY: req.Bbox.MinLat, MaxX: req.Bbox.MaxLng, MaxY: req.Bbox.MaxLat})
+	if err != nil {
+		return nil, grpcError(newBadRequestError(CodeInvalidBbox, "bbox", bboxString(req.Bbox), "%v", err))
+	}
+	vr := queryViewport(rect, chooseBestZoomBucket(int(req.Zoom)), int(req.Zoom), req.IncludePollingPlaces, nil)
+	response := &QueryViewportResponse{ElectorateIds: vr.electorateIds}
+	for id, locations := range vr.labels {
+		label := &ElectorateLabel{ElectorateId: string(id), Name: electorates[id].name}
+		for _, coords := range locations {
+			label.Locations = append(label.Locations, lngLat(coords))
+		}
+		response.Labels = append(response.Labels, label)
+	}
+	for _, i := range vr.pollingPlaces {
+		record := pollingPlaceRecord(i)
+		record.MinZoom = int32(viewportMinZoom(i))
+		response.PollingPlaces = append(response.PollingPlaces, record)
+	}
+	for _, g := range vr.pollingPlaceGroups {
+		response.PollingPlaceGroups = append(response.PollingPlaceGroups, &PollingPlaceGroupRecord{
+			Id:           g.ID(),
+			Location:     &LngLat{Lng: g.Lng, Lat: g.Lat},
+			MinZoom:      int32(g.minZoom),
+			Count:        int32(len(g.pollingPlaceIndices)),
+			DivisionName: string(g.divisionName),
+		})
+	}
+	// Labels come from a map, sort them so responses are stable.
+	sort.Sort(byLabelID(response.Labels))
+	return response, nil
+}
+
+type byLabelID []*ElectorateLabel
+
+func (l byLabelID) Len() int           { return len(l) }
+func (l byLabelID) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byLabelID) Less(i, j int) bool { return l[i].ElectorateId < l[j].ElectorateId }
+
+// grpcPollingPlaceFilter returns the filter of req, as the parameters of
+// /polling_places.
+func grpcPollingPlaceFilter(req *ListPollingPlacesRequest) (*pollingPlaceFilter, error) {
+	params := url.Values{}
+	params.Set("state", strings.Join(req.States, ","))
+	params.Set("wheelchair_access", strings.Join(req.WheelchairAccess, ","))
+	params.Set("status", strings.Join(req.Statuses, ","))
+	postcodes := make([]string, len(req.Postcodes))
+	for i, postcode := range req.Postcodes {
+		postcodes[i] = fmt.Sprint(postcode)
+	}
+	params.Set("postcode", strings.Join(postcodes, ","))
+	if req.MinOrdinaryVoteEstimate != 0 {
+		params.Set("min_ordinary_vote_estimate", fmt.Sprint(req.MinOrdinaryVoteEstimate))
+	}
+	params.Set("bbox", bboxString(req.Bbox))
+	return newPollingPlaceFilter(params.Get)
+}
+
+func (s *grpcServer) ListPollingPlaces(req *ListPollingPlacesRequest, stream ElectionService_ListPollingPlacesServer) error {
+	filter, err := grpcPollingPlaceFilter(req)
+	if err != nil {
+		return grpcError(err)
+	}
+	indices, err := selectPollingPlaces(strings.Join(req.ElectorateIds, ","), filter)
+	if err != nil {
+		return grpcError(err)
+	}
+	for _, i := range indices {
+		if err := stream.Send(pollingPlaceRecord(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected codes.Code
+	}{
+		{newUnknownElectorateError("nowhere"), codes.InvalidArgument},
+		{newUnknownAreaError(CodeUnknownPostcode, "pc", "0000"), codes.NotFound},
+		{&APIError{Status: 500, Code: CodeInternalError, Message: "oops"}, codes.Internal},
+	}
+	for _, tt := range tests {
+		s, _ := status.FromError(grpcError(tt.err))
+		if s.Code() != tt.expected {
+			t.Errorf("%v: expected %v, got %v", tt.err, tt.expected, s.Code())
+		}
+	}
+}
+
+type pollingPlaceRecordStream struct {
+	grpc.ServerStream
+	records []*PollingPlaceRecord
+}
+
+func (s *pollingPlaceRecordStream) Send(r *PollingPlaceRecord) error {
+	s.records = append(s.records, r)
+	return nil
+}
+
+func TestGRPCListPollingPlaces(t *testing.T) {
+	saved := electorates
+	defer func() { electorates = saved }()
+	// Allawah and Beverly Hills South, in Banks.
+	electorates = map[ElectorateID]*Electorate{
+		"banks": {
+			id: "banks",
+			polygons: map[ZoomLevel][]*ElectoratePolygon{
+				highestZoomLevel: {{pollingPlaces: []int{0, 3}}},
+			},
+		},
+	}
+	s := &grpcServer{}
+	stream := &pollingPlaceRecordStream{}
+	req := &ListPollingPlacesRequest{ElectorateIds: []string{"banks"}, WheelchairAccess: []string{"Full"}}
+	if err := s.ListPollingPlaces(req, stream); err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.records) != 1 || stream.records[0].PollingPlaceId != 79612 {
+		t.Errorf("Expected Beverly Hills South, got %v", stream.records)
+	}
+	req = &ListPollingPlacesRequest{States: []string{"NSW", "Tasmania"}}
+	if err := s.ListPollingPlaces(req, &pollingPlaceRecordStream{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an unknown state, got %v", err)
+	}
+}
+
+func TestGRPCQueryViewport(t *testing.T) {
+	withSquareElectorates(func() {
+		savedMinZoom, savedBuckets := pollingPlaceMinZoom, zoomBuckets
+		defer func() { pollingPlaceMinZoom, zoomBuckets = savedMinZoom, savedBuckets }()
+		zoomBuckets = []ZoomLevel{highestZoomLevel}
+		// Allawah is clustered at every zoom level, Allawah South is shown
+		// individually from zoom 10.
+		east := electorates["east"]
+		east.polygons[highestZoomLevel][0].pollingPlaces = []int{0, 1}
+		east.pplaceGrps = []pollingPlaceGroup{{pollingPlaceIndices: []int{0}, Lng: 151.1, Lat: -33.9, minZoom: 10, divisionName: "east"}}
+		pollingPlaceMinZoom = map[int]int{1: 10}
+		s := &grpcServer{}
+		req := &QueryViewportRequest{
+			Bbox:                 &BoundingBox{MinLng: 148, MinLat: -36, MaxLng: 152, MaxLat: -33},
+			Zoom:                 10,
+			IncludePollingPlaces: true,
+		}
+		response, err := s.QueryViewport(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(response.ElectorateIds, ",") != "east,west" {
+			t.Errorf("Expected east and west, got %v", response.ElectorateIds)
+		}
+		if len(response.PollingPlaces) != 1 || response.PollingPlaces[0].PollingPlaceId != 67 || response.PollingPlaces[0].MinZoom != 10 {
+			t.Errorf("Expected Allawah South from zoom 10, got %v", response.PollingPlaces)
+		}
+		if len(response.PollingPlaceGroups) != 1 {
+			t.Fatalf("Expected a group, got %v", response.PollingPlaceGroups)
+		}
+		if g := response.PollingPlaceGroups[0]; g.Id != "10_31" || g.Count != 1 || g.MinZoom != 10 || g.DivisionName != "east" {
+			t.Errorf("Unexpected group %v", g)
+		}
+		req.Zoom = MinZoomLevelToShowUngroupedPollingPlaces + 1
+		if response, err = s.QueryViewport(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+		if len(response.PollingPlaces) != 2 || response.PollingPlaces[0].MinZoom != req.Zoom || len(response.PollingPlaceGroups) != 0 {
+			t.Errorf("Expected both polling places individually, got %v and %v", response.PollingPlaces, response.PollingPlaceGroups)
+		}
+	})
+}
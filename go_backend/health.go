@@ -0,0 +1,165 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NotReadyRetryAfter is the number of seconds clients are asked to wait
+// while the dataset loads.
+const NotReadyRetryAfter = 10
+
+// InitPhase is a step of initSpatial and how long it took.
+type InitPhase struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// InitCounts are the sizes of the loaded dataset.
+type InitCounts struct {
+	Electorates        int `json:"electorates"`
+	ZoomBuckets        int `json:"zoom_buckets"`
+	PollingPlaces      int `json:"polling_places"`
+	PollingPlaceGroups int `json:"polling_place_groups"`
+}
+
+// HealthResponse is the response of /healthz and /readyz.
+type HealthResponse struct {
+	Ready    bool        `json:"ready"`
+	Started  time.Time   `json:"started"`
+	Finished *time.Time  `json:"finished,omitempty"`
+	Phases   []InitPhase `json:"phases"`
+	Counts   *InitCounts `json:"counts,omitempty"`
+}
+
+// initState records the progress of initSpatial. Any failure during
+// initialisation is fatal, so the process exits rather than staying unready.
+var initState struct {
+	sync.Mutex
+	started  time.Time
+	finished time.Time
+	phases   []InitPhase
+	counts   *InitCounts
+}
+
+func startInit() {
+	initState.Lock()
+	defer initState.Unlock()
+	initState.started = time.Now()
+	initState.phases = nil
+}
+
+// runInitPhase runs f, recording how long it took as phase name.
+func runInitPhase(name string, f func()) {
+	start := time.Now()
+	f()
+	d := time.Since(start)
+	log.Printf("Initialised %v in %v", name, d)
+	initState.Lock()
+	defer initState.Unlock()
+	initState.phases = append(initState.phases, InitPhase{
+		Name:       name,
+		DurationMs: float64(d) / float64(time.Millisecond),
+	})
+}
+
+// finishInit marks the dataset as loaded. As it takes the lock, everything
+// loaded before it is visible to requests which see isReady.
+func finishInit() {
+	counts := &InitCounts{
+		Electorates:   len(electorates),
+		ZoomBuckets:   len(zoomBuckets),
+		PollingPlaces: len(pollingPlaces),
+	}
+	for _, e := range electorates {
+		counts.PollingPlaceGroups += len(e.pplaceGrps)
+	}
+	initState.Lock()
+	defer initState.Unlock()
+	initState.finished = time.Now()
+	initState.counts = counts
+}
+
+func isReady() bool {
+	initState.Lock()
+	defer initState.Unlock()
+	return !initState.finished.IsZero()
+}
+
+func healthResponse() *HealthResponse {
+	initState.Lock()
+	defer initState.Unlock()
+	hr := &HealthResponse{
+		Started: initState.started,
+		Phases:  append([]InitPhase{}, initState.phases...),
+		Counts:  initState.counts,
+	}
+	if !initState.finished.IsZero() {
+		finished := initState.finished
+		hr.Ready = true
+		hr.Finished = &finished
+	}
+	return hr
+}
+
+func writeHealth(w http.ResponseWriter, status int) {
+	hr := healthResponse()
+	if hr.Ready {
+		status = http.StatusOK
+	}
+	// The state is per instance, it mustn't be cached.
+	w.Header().Set("Cache-control", "no-store")
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(hr)
+}
+
+// healthzQuery reports the initialisation state. It always succeeds, as the
+// process is alive even while the dataset loads.
+func healthzQuery(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK)
+}
+
+// readyzQuery reports the initialisation state, failing until the dataset
+// is loaded so that load balancers don't route traffic to the instance.
+func readyzQuery(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusServiceUnavailable)
+}
+
+// whenReady rejects requests until the dataset is loaded, and delegates to h
+// afterwards.
+func whenReady(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isReady() {
+			w.Header().Set("Retry-After", fmt.Sprint(NotReadyRetryAfter))
+			writeError(w, &APIError{
+				Status:  http.StatusServiceUnavailable,
+				Code:    CodeNotReady,
+				Message: "The dataset is still loading",
+			})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	defer func() {
+		initState.finished = time.Time{}
+	}()
+	h := newAPIHandler()
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	startInit()
+	runInitPhase("zoom_buckets", func() {})
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+		{"/v1/zoom_buckets", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		if w := get(tt.url); w.Code != tt.status {
+			t.Errorf("%v while loading: expected status %v, got %v", tt.url, tt.status, w.Code)
+		}
+	}
+	if retryAfter := get("/location").Header().Get("Retry-After"); retryAfter != "10" {
+		t.Errorf("Expected Retry-After 10 while loading, got %v", retryAfter)
+	}
+
+	finishInit()
+	w := get("/readyz")
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected /readyz to succeed once loaded, got %v", w.Code)
+	}
+	var hr HealthResponse
+	if err := json.NewDecoder(w.Body).Decode(&hr); err != nil {
+		t.Fatal(err)
+	}
+	if !hr.Ready || hr.Finished == nil || hr.Counts == nil {
+		t.Errorf("Expected ready with counts, got %+v", hr)
+	}
+	if len(hr.Phases) != 1 || hr.Phases[0].Name != "zoom_buckets" {
+		t.Errorf("Expected the zoom_buckets phase, got %v", hr.Phases)
+	}
+	if cc := w.Header().Get("Cache-control"); cc != "no-store" {
+		t.Errorf("Expected health not to be cached, got %v", cc)
+	}
+}
@@ -19,25 +19,56 @@ package election
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/paulmach/go.geojson"
 )
 
-const allowEncodedPolylineFeatureCollection = true
-
 // NewAPIHandler creates a single http.Handler for the election library HTTP API.
 // Note that index is a separate http.HandlerFunc, as it requires a different set of headers.
+// The handler should be mounted at each of APIPatterns. It blocks until the
+// dataset is loaded.
 func NewAPIHandler() http.Handler {
 	initSpatial()
+	return newAPIHandler()
+}
+
+// responseCache wraps the handler of every route, see CacheResponses.
+var responseCache func(http.Handler) http.Handler
+
+// CacheResponses wraps the handler of every route with cache, e.g. a
+// memcache middleware, and must be called before NewAPIHandler. The cache
+// is inside the route's rate limit, so cached responses are limited too,
+// and outside its parameter validation, so it must only cache successful
+// responses.
+func CacheResponses(cache func(http.Handler) http.Handler) {
+	responseCache = cache
+}
+
+// NewBackgroundAPIHandler is like NewAPIHandler, but returns immediately and
+// loads the dataset in the background. Until it's loaded, /readyz and every
+// API endpoint respond with 503, so that a load balancer polling /readyz only
+// routes traffic once the heavy startup is done.
+func NewBackgroundAPIHandler() http.Handler {
+	h := newAPIHandler()
+	go initSpatial()
+	return h
+}
+
+// newAPIHandler serves the health and metrics endpoints, which are neither
+// versioned nor rate limited, and the API once the dataset is loaded.
+func newAPIHandler() http.Handler {
 	r := mux.NewRouter()
-	r.HandleFunc("/electorates/{zoom}", electoratesQuery)
-	r.HandleFunc("/location", locationQuery)
-	r.HandleFunc("/viewport/{zoom}", viewportQuery)
-	r.HandleFunc("/zoom_buckets", zoomBucketsQuery)
-	r.HandleFunc("/polling_places", pollingPlacesQuery)
+	r.HandleFunc("/healthz", healthzQuery)
+	r.HandleFunc("/readyz", readyzQuery)
+	r.HandleFunc("/metrics", metricsQuery)
+	r.PathPrefix("/").Handler(whenReady(
+		etagMiddleware(newAPIRouter(apiVersions, newRateLimiterFromEnv()))))
 	return r
 }
 
@@ -58,57 +89,102 @@ func CommonHeadersMiddleware(h http.Handler) http.Handler {
 		})
 }
 
+// parseZoom parses the zoom route variable.
+func parseZoom(r *http.Request) (ZoomLevel, int, error) {
+	z := mux.Vars(r)["zoom"]
+	zoom, originalZoom, err := parseZoomParameter(z)
+	if err != nil {
+		return NoZoomLevel, 0, newBadRequestError(CodeInvalidZoom, "zoom", z, "%v", err)
+	}
+	return zoom, originalZoom, nil
+}
+
 func viewportQuery(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	zoom, originalZoom, err := parseZoomParameter(vars["zoom"])
+	zoom, originalZoom, err := parseZoom(r)
 	if err != nil {
-		http.Error(w, "Invalid zoom", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	rect, err := ParseBboxToRect(r.FormValue("bbox"))
+	bbox := r.FormValue("bbox")
+	rect, err := ParseBboxToRect(bbox)
 	if err != nil {
-		http.Error(w, "Invalid bbox", http.StatusBadRequest)
+		writeError(w, newBadRequestError(CodeInvalidBbox, "bbox", bbox, "Invalid bbox: %v", err))
 		return
 	}
-	vr := queryViewport(rect, zoom, originalZoom)
-	w.Header().Set("Cache-control", "public, max-age=120")
-	w.Header().Set("Content-type", "application/json")
-	err = json.NewEncoder(w).Encode(vr)
+	includePollingPlaces := false
+	if include := r.FormValue("include"); include != "" {
+		for _, i := range strings.Split(include, ",") {
+			if i != IncludePollingPlaces {
+				writeError(w, newBadRequestError(CodeInvalidParameter, "include", i,
+					"Expected include to be %v", IncludePollingPlaces))
+				return
+			}
+			includePollingPlaces = true
+		}
+	}
+	fields, err := parsePollingPlaceFields(r.FormValue("fields"))
 	if err != nil {
-		http.Error(w, "Invalid JSON response", http.StatusInternalServerError)
+		writeError(w, err)
+		return
 	}
+	vr := queryViewport(rect, zoom, originalZoom, includePollingPlaces, fields)
+	writeFeatureCollection(w, r, vr.FeatureCollection, viewportFormats)
 }
 
 func electoratesQuery(w http.ResponseWriter, r *http.Request) {
 	if len(electorates) == 0 {
-		http.Error(w, "No electorates loaded", http.StatusInternalServerError)
+		writeError(w, fmt.Errorf("No electorates loaded"))
 		return
 	}
-	vars := mux.Vars(r)
-	zoom, _, err := parseZoomParameter(vars["zoom"])
+	zoom, _, err := parseZoom(r)
 	if err != nil {
-		http.Error(w, "Invalid zoom", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
 	ids := r.FormValue("ids")
 	if ids == "" {
-		http.Error(w, "No electorate ID specified", http.StatusBadRequest)
+		writeError(w, newBadRequestError(CodeMissingParameter, "ids", "", "No electorate ID specified"))
+		return
+	}
+	encoder := negotiateEncoder(w, r, electorateFormats)
+	if encoder == nil {
 		return
 	}
-	fc, err := queryElectorates(zoom, ids)
+	electorateIds, err := queryElectorateIDs(zoom, ids)
 	if err != nil {
-		http.Error(w, "Invalid electorates", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	var epfc MarshalJSON = fc
-	if allowEncodedPolylineFeatureCollection {
-		epfc = maybeConvertToEncodedPolylineFeatureCollection(fc)
+	encode := func(w io.Writer) error {
+		if encoder.EncodeFeature == nil {
+			fc, err := queryElectorates(zoom, ids)
+			if err != nil {
+				return err
+			}
+			return encoder.Encode(w, fc)
+		}
+		// Feature collections of every electorate are large, so JSON
+		// formats are written a feature at a time.
+		return streamFeatureCollection(w, encoder, electoratesBoundingBox(electorateIds), len(electorateIds),
+			func(i int) (*geojson.Feature, error) {
+				return electorateToGeoJsonFeature(electorateIds[i], zoom)
+			})
 	}
-	w.Header().Set("Cache-control", "public, max-age=120")
-	w.Header().Set("Content-type", "application/json")
-	err = json.NewEncoder(w).Encode(epfc)
+	// Electorate geometry never changes, so compressed responses are
+	// kept rather than compressed on every request.
+	key := fmt.Sprintf("electorates;%v;%v;%v", zoom, canonicalElectorateIDs(ids), encoder.Format)
+	handled, err := writePrecompressed(w, r, key, encoder.ContentType, encode)
 	if err != nil {
-		http.Error(w, "Invalid JSON response", http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	if handled {
+		return
+	}
+	w.Header().Set("Cache-control", "public, max-age=120")
+	w.Header().Set("Content-type", encoder.ContentType)
+	if err := encode(w); err != nil {
+		writeError(w, err)
 	}
 }
 
@@ -117,60 +193,305 @@ func zoomBucketsQuery(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-type", "application/json")
 	err := json.NewEncoder(w).Encode(zoomBuckets)
 	if err != nil {
-		http.Error(w, "Invalid JSON response", http.StatusInternalServerError)
+		writeError(w, err)
 	}
 }
 
-func locationQuery(w http.ResponseWriter, r *http.Request) {
-	location := r.FormValue("location")
+// parseLocationParameter parses a 'lat,lng' string.
+func parseLocationParameter(location string) (float64, float64, error) {
 	if location == "" {
-		http.Error(w, "location parameter required", http.StatusBadRequest)
-		return
+		return 0, 0, newBadRequestError(CodeMissingParameter, "location", "", "location parameter required")
 	}
 	components := strings.Split(location, ",")
 	if len(components) != 2 {
-		http.Error(w, "location parameter invalid format", http.StatusBadRequest)
-		return
+		return 0, 0, newBadRequestError(CodeInvalidLocation, "location", location, "location parameter invalid format, expected 'lat,lng'")
 	}
 	lat, err := strconv.ParseFloat(components[0], 64)
 	if err != nil {
-		http.Error(w, "invalid lat", http.StatusBadRequest)
-		return
+		return 0, 0, newBadRequestError(CodeInvalidLocation, "location", location, "invalid lat")
 	}
 	lng, err := strconv.ParseFloat(components[1], 64)
 	if err != nil {
-		http.Error(w, "invalid lng", http.StatusBadRequest)
+		return 0, 0, newBadRequestError(CodeInvalidLocation, "location", location, "invalid lng")
+	}
+	return lat, lng, nil
+}
+
+func locationQuery(w http.ResponseWriter, r *http.Request) {
+	lat, lng, err := parseLocationParameter(r.FormValue("location"))
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	name := queryLocation(lng, lat)
-	if name == "" {
-		http.NotFound(w, r)
+	response := queryLocationResponse(lng, lat)
+	if response == nil {
+		writeError(w, newLocationNotInElectorateError(r.FormValue("location")))
 		return
 	}
 	w.Header().Set("Cache-control", "public, max-age=120")
 	w.Header().Set("Content-type", "application/json")
-	response := struct{ Name string }{Name: name}
 	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
-		http.Error(w, "Invalid JSON response", http.StatusInternalServerError)
+		writeError(w, err)
 	}
 }
 
-func pollingPlacesQuery(w http.ResponseWriter, r *http.Request) {
+// NDJSONContentType is the content type of newline delimited JSON, accepted
+// as an alternative to a JSON array by locationsQuery.
+const NDJSONContentType = "application/x-ndjson"
+
+// decodeBatchLocations reads either a JSON array or a stream of newline
+// delimited JSON objects of locations from body. Locations are decoded one at
+// a time, so that requests with too many are rejected without reading them
+// all.
+func decodeBatchLocations(body io.Reader, ndjson bool) ([]BatchLocation, error) {
+	var locations []BatchLocation
+	decoder := json.NewDecoder(body)
+	if !ndjson {
+		t, err := decoder.Token()
+		if err != nil {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "Invalid locations: %v", err)
+		}
+		if t != json.Delim('[') {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "Invalid locations: expected an array")
+		}
+		for decoder.More() {
+			if len(locations) == MaxBatchLocations {
+				return nil, newBadRequestError(CodeInvalidBody, "", "", "More than %v locations", MaxBatchLocations)
+			}
+			var l BatchLocation
+			if err := decoder.Decode(&l); err != nil {
+				return nil, newBadRequestError(CodeInvalidBody, "", "", "Invalid location %v: %v", len(locations)+1, err)
+			}
+			locations = append(locations, l)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "Invalid locations: %v", err)
+		}
+		return locations, nil
+	}
+	for {
+		var l BatchLocation
+		err := decoder.Decode(&l)
+		if err == io.EOF {
+			return locations, nil
+		}
+		if err != nil {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "Invalid location on line %v: %v", len(locations)+1, err)
+		}
+		if len(locations) == MaxBatchLocations {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "More than %v locations", MaxBatchLocations)
+		}
+		locations = append(locations, l)
+	}
+}
+
+func locationsQuery(w http.ResponseWriter, r *http.Request) {
+	ndjson := strings.HasPrefix(r.Header.Get("Content-type"), NDJSONContentType)
+	body := http.MaxBytesReader(w, r.Body, MaxBatchLocationsBodyBytes)
+	locations, err := decodeBatchLocations(body, ndjson)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	results := queryLocations(locations)
+	w.Header().Set("Cache-control", "no-store")
+	if !ndjson {
+		w.Header().Set("Content-type", "application/json")
+		err = json.NewEncoder(w).Encode(results)
+		if err != nil {
+			writeError(w, err)
+		}
+		return
+	}
+	w.Header().Set("Content-type", NDJSONContentType)
+	encoder := json.NewEncoder(w)
+	for _, result := range results {
+		// Headers have already been sent, so there's no way to report
+		// an error to the client.
+		if err := encoder.Encode(result); err != nil {
+			return
+		}
+	}
+}
+
+// DefaultNearestPollingPlaces is the number of polling places returned by
+// nearestPollingPlacesQuery when k isn't specified.
+const DefaultNearestPollingPlaces = 5
+
+// MaxNearestPollingPlaces is the largest k accepted by
+// nearestPollingPlacesQuery.
+const MaxNearestPollingPlaces = 50
+
+func nearestPollingPlacesQuery(w http.ResponseWriter, r *http.Request) {
+	lat, lng, err := parseLocationParameter(r.FormValue("location"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	k := DefaultNearestPollingPlaces
+	if kParam := r.FormValue("k"); kParam != "" {
+		k, err = strconv.Atoi(kParam)
+		if err != nil || k < 1 || k > MaxNearestPollingPlaces {
+			writeError(w, newBadRequestError(CodeInvalidParameter, "k", kParam,
+				"Expected k to be an integer between 1 and %v", MaxNearestPollingPlaces))
+			return
+		}
+	}
+	sameElectorate := false
+	if s := r.FormValue("same_electorate"); s != "" {
+		sameElectorate, err = strconv.ParseBool(s)
+		if err != nil {
+			writeError(w, newBadRequestError(CodeInvalidParameter, "same_electorate", s,
+				"Expected same_electorate to be true or false"))
+			return
+		}
+	}
+	fields, err := parsePollingPlaceFields(r.FormValue("fields"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	fc, err := queryNearestPollingPlaces(lng, lat, k, sameElectorate, fields)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeFeatureCollection(w, r, fc, pollingPlaceFormats)
+}
+
+// parsePollingPlaceSelection returns the electorate IDs and filter of a
+// request for polling places, which must have one of ids, state or bbox.
+func parsePollingPlaceSelection(r *http.Request) (string, *pollingPlaceFilter, error) {
 	ids := r.FormValue("ids")
-	if ids == "" {
-		http.Error(w, "No electorate ID specified", http.StatusBadRequest)
+	if ids == "" && r.FormValue("state") == "" && r.FormValue("bbox") == "" {
+		return "", nil, newBadRequestError(CodeMissingParameter, "ids", "", "One of ids, state or bbox is required")
+	}
+	filter, err := parsePollingPlaceFilter(r)
+	if err != nil {
+		return "", nil, err
+	}
+	return ids, filter, nil
+}
+
+func pollingPlacesQuery(w http.ResponseWriter, r *http.Request) {
+	ids, filter, err := parsePollingPlaceSelection(r)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
-	fc, err := queryPollingPlaces(ids)
+	fields, err := parsePollingPlaceFields(r.FormValue("fields"))
 	if err != nil {
-		http.Error(w, "Invalid polling places", http.StatusBadRequest)
+		writeError(w, err)
+		return
+	}
+	fc, err := queryPollingPlaces(ids, filter, fields)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeFeatureCollection(w, r, fc, pollingPlaceFormats)
+}
+
+func writeAreaElectorates(w http.ResponseWriter, response *AreaElectorates, err error) {
+	if err != nil {
+		writeError(w, err)
 		return
 	}
 	w.Header().Set("Cache-control", "public, max-age=120")
 	w.Header().Set("Content-type", "application/json")
-	err = json.NewEncoder(w).Encode(fc)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, err)
+	}
+}
+
+func postcodeQuery(w http.ResponseWriter, r *http.Request) {
+	response, err := queryPostcode(mux.Vars(r)["pc"])
+	writeAreaElectorates(w, response, err)
+}
+
+func suburbQuery(w http.ResponseWriter, r *http.Request) {
+	response, err := querySuburb(mux.Vars(r)["name"], r.FormValue("state"))
+	writeAreaElectorates(w, response, err)
+}
+
+func graphQLQuery(w http.ResponseWriter, r *http.Request) {
+	req, err := parseGraphQLRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	result := executeGraphQL(r.Context(), req)
+	if r.Method == "POST" {
+		w.Header().Set("Cache-control", "no-store")
+	} else {
+		w.Header().Set("Cache-control", "public, max-age=120")
+	}
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		writeError(w, err)
+	}
+}
+
+func searchQuery(w http.ResponseWriter, r *http.Request) {
+	q := r.FormValue("q")
+	if strings.TrimSpace(q) == "" {
+		writeError(w, newBadRequestError(CodeMissingParameter, "q", "", "q parameter required"))
+		return
+	}
+	if len(q) > MaxSearchQueryLength {
+		writeError(w, newBadRequestError(CodeInvalidParameter, "q", q,
+			"Expected q to be at most %v characters", MaxSearchQueryLength))
+		return
+	}
+	if len(tokenize(q)) > MaxSearchTerms {
+		writeError(w, newBadRequestError(CodeInvalidParameter, "q", q,
+			"Expected q to have at most %v words", MaxSearchTerms))
+		return
+	}
+	limit := DefaultSearchResults
+	if l := r.FormValue("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > MaxSearchResults {
+			writeError(w, newBadRequestError(CodeInvalidParameter, "limit", l,
+				"Expected limit to be an integer between 1 and %v", MaxSearchResults))
+			return
+		}
+	}
+	fields, err := parsePollingPlaceFields(r.FormValue("fields"))
 	if err != nil {
-		http.Error(w, "Invalid JSON response", http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+	writeFeatureCollection(w, r, querySearch(q, limit, fields), pollingPlaceFormats)
+}
+
+// VectorTileContentType is the content type of Mapbox Vector Tiles.
+const VectorTileContentType = "application/vnd.mapbox-vector-tile"
+
+func tileQuery(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	// The route only matches digits, so these can only fail on overflow.
+	z, errZ := strconv.Atoi(vars["z"])
+	x, errX := strconv.Atoi(vars["x"])
+	y, errY := strconv.Atoi(vars["y"])
+	tileID := fmt.Sprintf("%v/%v/%v", vars["z"], vars["x"], vars["y"])
+	if errZ != nil || errX != nil || errY != nil {
+		writeError(w, newBadRequestError(CodeInvalidTile, "tile", tileID, "Invalid tile"))
+		return
 	}
+	t, err := newTileCoord(z, x, y)
+	if err != nil {
+		writeError(w, newBadRequestError(CodeInvalidTile, "tile", tileID, "%v", err))
+		return
+	}
+	tile, err := queryTile(t)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Cache-control", "public, max-age=120")
+	w.Header().Set("Content-type", VectorTileContentType)
+	w.Write(tile)
 }
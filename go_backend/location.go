@@ -0,0 +1,170 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"math"
+
+	rtree "github.com/dhconnelly/rtreego"
+	shp "github.com/jonas-p/go-shp"
+)
+
+// NeighbourDistanceMetres is how close another electorate has to be to a
+// location for /location to report it as a neighbour, as geocodes of
+// addresses on boundary streets often fall on the wrong side.
+const NeighbourDistanceMetres = 250
+
+// metresPerDegree is the length of a degree of latitude, or of longitude at
+// the equator.
+const metresPerDegree = EarthRadius * 1000 * math.Pi / 180
+
+// LocationNeighbour is an electorate near a location which doesn't contain it.
+type LocationNeighbour struct {
+	Name           string
+	ID             ElectorateID
+	State          string
+	DistanceMetres float64
+}
+
+// LocationResponse is the response of /location: the electorate containing
+// a location and how close the location is to its boundary.
+type LocationResponse struct {
+	Name  string
+	ID    ElectorateID
+	State string
+	// GISID identifies the polygon of the electorate containing the location.
+	GISID string
+	// BoundaryDistanceMetres is the distance to the nearest boundary of the
+	// electorate, which may be with another electorate or the coast.
+	BoundaryDistanceMetres float64
+	// Neighbour is the nearest other electorate, if it's within
+	// NeighbourDistanceMetres.
+	Neighbour *LocationNeighbour `json:",omitempty"`
+}
+
+// localProjection projects longitudes and latitudes to metres from an origin,
+// with an equirectangular projection. It's accurate enough for distances of
+// the order of NeighbourDistanceMetres.
+type localProjection struct {
+	lng, lat float64
+	lngScale float64
+}
+
+func newLocalProjection(lng, lat float64) *localProjection {
+	return &localProjection{lng: lng, lat: lat, lngScale: cos(lat) * metresPerDegree}
+}
+
+func (lp *localProjection) project(p shp.Point) (float64, float64) {
+	return (p.X - lp.lng) * lp.lngScale, (p.Y - lp.lat) * metresPerDegree
+}
+
+// segmentDistance returns the distance from the origin to the segment from
+// (x1, y1) to (x2, y2).
+func segmentDistance(x1, y1, x2, y2 float64) float64 {
+	dx, dy := x2-x1, y2-y1
+	t := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		t = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/lengthSq))
+	}
+	x, y := x1+t*dx, y1+t*dy
+	return math.Sqrt(x*x + y*y)
+}
+
+// polygonsDistanceMetres returns the distance in metres from the origin of lp
+// to the nearest edge of eps, or max if there's none closer.
+func polygonsDistanceMetres(lp *localProjection, eps []*ElectoratePolygon, max float64) float64 {
+	nearest := max
+	for _, ep := range eps {
+		for _, ring := range polygonRings(ep) {
+			for i := 1; i < len(ring); i++ {
+				x1, y1 := lp.project(ring[i-1])
+				x2, y2 := lp.project(ring[i])
+				// Skip segments entirely further away on either axis.
+				if math.Min(x1, x2) > nearest || math.Max(x1, x2) < -nearest ||
+					math.Min(y1, y2) > nearest || math.Max(y1, y2) < -nearest {
+					continue
+				}
+				nearest = math.Min(nearest, segmentDistance(x1, y1, x2, y2))
+			}
+		}
+	}
+	return nearest
+}
+
+// nearestNeighbour returns the electorate other than e nearest to the origin
+// of lp within NeighbourDistanceMetres, amongst candidates, or nil if there's
+// none.
+func nearestNeighbour(lp *localProjection, e *Electorate, candidates []*Electorate) *LocationNeighbour {
+	var neighbour *LocationNeighbour
+	nearest := float64(NeighbourDistanceMetres)
+	for _, candidate := range candidates {
+		if candidate == e {
+			continue
+		}
+		d := polygonsDistanceMetres(lp, candidate.polygons[highestZoomLevel], nearest)
+		if d < nearest {
+			nearest = d
+			neighbour = &LocationNeighbour{
+				Name:           candidate.name,
+				ID:             candidate.id,
+				State:          candidate.state,
+				DistanceMetres: math.Floor(d + 0.5),
+			}
+		}
+	}
+	return neighbour
+}
+
+// newLocationResponse returns the response for a location in ep of e, given
+// the electorates which may neighbour it.
+func newLocationResponse(lng, lat float64, e *Electorate, ep *ElectoratePolygon, candidates []*Electorate) *LocationResponse {
+	lp := newLocalProjection(lng, lat)
+	return &LocationResponse{
+		Name:                   e.name,
+		ID:                     e.id,
+		State:                  e.state,
+		GISID:                  ep.gisid,
+		BoundaryDistanceMetres: math.Floor(polygonsDistanceMetres(lp, e.polygons[highestZoomLevel], math.Inf(1)) + 0.5),
+		Neighbour:              nearestNeighbour(lp, e, candidates),
+	}
+}
+
+// queryLocationResponse returns the electorate containing the given point
+// with its proximity to other electorates, or nil if the point isn't in any
+// electorate.
+func queryLocationResponse(lng, lat float64) *LocationResponse {
+	e, ep := locateElectoratePolygon(lng, lat)
+	if e == nil {
+		return nil
+	}
+	// Electorates whose bounding box is within NeighbourDistanceMetres.
+	lngDistance := NeighbourDistanceMetres / (cos(lat) * metresPerDegree)
+	latDistance := NeighbourDistanceMetres / metresPerDegree
+	rect, err := rtree.NewRect(rtree.Point{lng - lngDistance, lat - latDistance},
+		[]float64{2 * lngDistance, 2 * latDistance})
+	if err != nil {
+		return newLocationResponse(lng, lat, e, ep, nil)
+	}
+	var candidates []*Electorate
+	for _, spatial := range searchElectorates("neighbours", rect) {
+		if candidate, ok := spatial.(*Electorate); ok {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return newLocationResponse(lng, lat, e, ep, candidates)
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"runtime"
+	"sync"
+)
+
+// MaxBatchLocations is the maximum number of points accepted in a single
+// batch location request.
+const MaxBatchLocations = 50000
+
+// MaxBatchLocationsBodyBytes is the largest batch location request body
+// accepted, enough for MaxBatchLocations locations with short IDs.
+const MaxBatchLocationsBodyBytes = 8 << 20
+
+// BatchLocation is a single point in a batch location request. ID is chosen
+// by the caller and echoed back in the matching BatchLocationResult.
+type BatchLocation struct {
+	ID  string  `json:"id"`
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// BatchLocationResult is the electorate resolved for a BatchLocation. The
+// electorate fields are omitted when the point isn't in any electorate.
+type BatchLocationResult struct {
+	ID           string `json:"id"`
+	ElectorateID string `json:"electorate_id,omitempty"`
+	Name         string `json:"name,omitempty"`
+	State        string `json:"state,omitempty"`
+}
+
+// queryLocations resolves the electorate for each of the given locations.
+// Results are in the same order as locations. The work is split between a
+// worker per CPU, electorateTree and the polygons are only read so they are
+// safe to share.
+func queryLocations(locations []BatchLocation) []BatchLocationResult {
+	results := make([]BatchLocationResult, len(locations))
+	workers := runtime.NumCPU()
+	if workers > len(locations) {
+		workers = len(locations)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Each worker handles every workers'th location.
+			for i := w; i < len(locations); i += workers {
+				l := locations[i]
+				results[i].ID = l.ID
+				e := locateElectorate(l.Lng, l.Lat)
+				if e == nil {
+					continue
+				}
+				results[i].ElectorateID = string(e.id)
+				results[i].Name = e.name
+				results[i].State = e.state
+			}
+		}(w)
+	}
+	wg.Wait()
+	return results
+}
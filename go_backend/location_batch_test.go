@@ -0,0 +1,168 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	rtree "github.com/dhconnelly/rtreego"
+	shp "github.com/jonas-p/go-shp"
+)
+
+// withSquareElectorates sets electorates and electorateTree to West and East,
+// one degree squares either side of longitude 150, for the duration of f.
+func withSquareElectorates(f func()) {
+	savedElectorates, savedTree := electorates, electorateTree
+	defer func() { electorates, electorateTree = savedElectorates, savedTree }()
+	square := func(id ElectorateID, name string, minX, minY float64) *Electorate {
+		points := []shp.Point{
+			{X: minX, Y: minY + 1}, {X: minX + 1, Y: minY + 1}, {X: minX + 1, Y: minY},
+			{X: minX, Y: minY}, {X: minX, Y: minY + 1},
+		}
+		box := shp.BBoxFromPoints(points)
+		return &Electorate{id: id, name: name, state: "NSW", bbox: &box,
+			polygons: map[ZoomLevel][]*ElectoratePolygon{highestZoomLevel: {{Polygon: &shp.Polygon{
+				Box:       box,
+				NumParts:  1,
+				NumPoints: int32(len(points)),
+				Parts:     []int32{0},
+				Points:    points,
+			}}}}}
+	}
+	electorates = map[ElectorateID]*Electorate{
+		"west": square("west", "West", 149, -35),
+		"east": square("east", "East", 150, -35),
+	}
+	electorateTree = rtree.NewTree(2, 16, 32)
+	for _, e := range electorates {
+		electorateTree.Insert(e)
+	}
+	f()
+}
+
+func TestQueryLocations(t *testing.T) {
+	withSquareElectorates(func() {
+		locations := []BatchLocation{
+			{ID: "east", Lat: -34.5, Lng: 150.5},
+			{ID: "sea", Lat: -34.5, Lng: 155},
+			{ID: "west", Lat: -34.5, Lng: 149.5},
+		}
+		// Enough locations for every worker to have several.
+		for i := 0; i < 100; i++ {
+			locations = append(locations, BatchLocation{ID: fmt.Sprint(i), Lat: -34.5, Lng: 149.01 + float64(i)/50})
+		}
+		results := queryLocations(locations)
+		if len(results) != len(locations) {
+			t.Fatalf("Expected %v results, got %v", len(locations), len(results))
+		}
+		expected := []BatchLocationResult{
+			{ID: "east", ElectorateID: "east", Name: "East", State: "NSW"},
+			{ID: "sea"},
+			{ID: "west", ElectorateID: "west", Name: "West", State: "NSW"},
+		}
+		for i, e := range expected {
+			if results[i] != e {
+				t.Errorf("Expected %+v, got %+v", e, results[i])
+			}
+		}
+		for i, r := range results[len(expected):] {
+			id := "west"
+			if i >= 50 {
+				id = "east"
+			}
+			if r.ID != fmt.Sprint(i) || r.ElectorateID != id {
+				t.Errorf("Expected location %v in %v, got %+v", i, id, r)
+			}
+		}
+	})
+}
+
+func TestDecodeBatchLocations(t *testing.T) {
+	locations, err := decodeBatchLocations(strings.NewReader(`[{"id": "a", "lat": -34.5, "lng": 150.5}, {"id": "b"}]`), false)
+	if err != nil || len(locations) != 2 || locations[0] != (BatchLocation{ID: "a", Lat: -34.5, Lng: 150.5}) {
+		t.Errorf("Unexpected locations %v: %v", locations, err)
+	}
+	locations, err = decodeBatchLocations(strings.NewReader("{\"id\": \"a\"}\n{\"id\": \"b\"}\n"), true)
+	if err != nil || len(locations) != 2 || locations[1].ID != "b" {
+		t.Errorf("Unexpected locations %v: %v", locations, err)
+	}
+	for _, body := range []string{`{"id": "a"}`, `[{"id": "a"}`, `[{"id": 1}]`} {
+		if _, err := decodeBatchLocations(strings.NewReader(body), false); err == nil {
+			t.Errorf("%v: expected an error", body)
+		}
+	}
+}
+
+func TestDecodeBatchLocationsTooMany(t *testing.T) {
+	location := `{"id": "a", "lat": -34.5, "lng": 150.5}`
+	tooMany := strings.Repeat(location+",", MaxBatchLocations) + location
+	if _, err := decodeBatchLocations(strings.NewReader("["+tooMany+"]"), false); err == nil ||
+		!strings.Contains(err.Error(), "More than") {
+		t.Errorf("Expected too many locations, got %v", err)
+	}
+	tooMany = strings.Repeat(location+"\n", MaxBatchLocations+1)
+	if _, err := decodeBatchLocations(strings.NewReader(tooMany), true); err == nil ||
+		!strings.Contains(err.Error(), "More than") {
+		t.Errorf("Expected too many locations, got %v", err)
+	}
+}
+
+func TestLocationsQueryBodyTooLarge(t *testing.T) {
+	body := "[" + strings.Repeat(" ", MaxBatchLocationsBodyBytes) + "]"
+	w := httptest.NewRecorder()
+	locationsQuery(w, httptest.NewRequest("POST", "/locations", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), CodeInvalidBody) {
+		t.Errorf("Expected a 400 %v error, got %v %v", CodeInvalidBody, w.Code, w.Body)
+	}
+}
+
+func TestBatchLocationSchemas(t *testing.T) {
+	values := map[string]interface{}{
+		"BatchLocation":       BatchLocation{ID: "a", Lat: -34.5, Lng: 150.5},
+		"BatchLocationResult": BatchLocationResult{ID: "a", ElectorateID: "banks", Name: "Banks", State: "NSW"},
+	}
+	for name, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(b, &fields); err != nil {
+			t.Fatal(err)
+		}
+		schema := openAPISchemas[name]
+		if len(fields) != len(schema.Properties) {
+			t.Errorf("%v: expected the properties %v, got %v", name, fields, schema.Properties)
+		}
+		for field := range fields {
+			if schema.Properties[field] == nil {
+				t.Errorf("%v: expected a %v property", name, field)
+			}
+		}
+		for _, field := range schema.Required {
+			if _, ok := fields[field]; !ok {
+				t.Errorf("%v: required %v isn't a field", name, field)
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"math"
+	"testing"
+
+	shp "github.com/jonas-p/go-shp"
+)
+
+func TestNewLocationResponse(t *testing.T) {
+	square := func(gisid string, minX, minY float64) *ElectoratePolygon {
+		points := []shp.Point{
+			{X: minX, Y: minY}, {X: minX, Y: minY + 1}, {X: minX + 1, Y: minY + 1},
+			{X: minX + 1, Y: minY}, {X: minX, Y: minY},
+		}
+		return &ElectoratePolygon{
+			Polygon: &shp.Polygon{
+				Box:       shp.BBoxFromPoints(points),
+				NumParts:  1,
+				NumPoints: int32(len(points)),
+				Parts:     []int32{0},
+				Points:    points,
+			},
+			gisid: gisid,
+		}
+	}
+	// Two electorates either side of longitude 150.
+	west := &Electorate{id: "west", name: "West", state: "NSW",
+		polygons: map[ZoomLevel][]*ElectoratePolygon{highestZoomLevel: {square("1", 149, -35)}}}
+	east := &Electorate{id: "east", name: "East", state: "NSW",
+		polygons: map[ZoomLevel][]*ElectoratePolygon{highestZoomLevel: {square("2", 150, -35)}}}
+	candidates := []*Electorate{west, east}
+	metres := func(dLng float64) float64 {
+		return math.Floor(distanceMetres(150-dLng, -34.5, 150, -34.5) + 0.5)
+	}
+
+	// About 90 metres from the boundary.
+	r := newLocationResponse(149.999, -34.5, west, west.polygons[highestZoomLevel][0], candidates)
+	if r.ID != "west" || r.Name != "West" || r.State != "NSW" || r.GISID != "1" {
+		t.Errorf("Expected West, got %+v", r)
+	}
+	if d := r.BoundaryDistanceMetres; math.Abs(d-metres(0.001)) > 1 {
+		t.Errorf("Expected %v metres to the boundary, got %v", metres(0.001), d)
+	}
+	if r.Neighbour == nil || r.Neighbour.ID != "east" || r.Neighbour.DistanceMetres != r.BoundaryDistanceMetres {
+		t.Errorf("Expected East as the neighbour, got %+v", r.Neighbour)
+	}
+
+	// About 900 metres from the boundary.
+	r = newLocationResponse(149.99, -34.5, west, west.polygons[highestZoomLevel][0], candidates)
+	if r.Neighbour != nil {
+		t.Errorf("Expected no neighbour, got %+v", r.Neighbour)
+	}
+	if d := r.BoundaryDistanceMetres; math.Abs(d-metres(0.01)) > 1 {
+		t.Errorf("Expected %v metres to the boundary, got %v", metres(0.01), d)
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsRegistry holds the metrics served at /metrics.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "election_http_requests_total",
+		Help: "Requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "election_http_request_duration_seconds",
+		Help:    "Time to respond to requests by route.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"route"})
+	responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "election_http_response_size_bytes",
+		Help:    "Size of responses by route, before compression.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"route"})
+	rtreeSearches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "election_rtree_searches_total",
+		Help: "Searches of the electorate R-tree by query.",
+	}, []string{"query"})
+	rtreeHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "election_rtree_search_hits_total",
+		Help: "Electorates found by searches of the electorate R-tree by query.",
+	}, []string{"query"})
+	pointInPolygonEvaluations = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "election_point_in_polygon_evaluations_total",
+		Help: "Polygons tested for containing a location.",
+	})
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "election_cache_lookups_total",
+		Help: "Lookups of responses in the App Engine memcache, by result (hit or miss).",
+	}, []string{"result"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		prometheus.NewGoCollector(),
+		requestsTotal,
+		requestDuration,
+		responseSize,
+		rtreeSearches,
+		rtreeHits,
+		pointInPolygonEvaluations,
+		cacheLookups,
+	)
+}
+
+// RecordCacheLookup counts a lookup of a response in a cache in front of the
+// API, from which the hit ratio can be computed.
+func RecordCacheLookup(hit bool) {
+	if hit {
+		cacheLookups.WithLabelValues("hit").Inc()
+	} else {
+		cacheLookups.WithLabelValues("miss").Inc()
+	}
+}
+
+// metricsHandler serves the metrics of this instance in the Prometheus
+// format. The API is compressed by CompressionMiddleware, so promhttp mustn't
+// compress too.
+var metricsHandler = promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{DisableCompression: true})
+
+// metricsQuery serves /metrics.
+func metricsQuery(w http.ResponseWriter, r *http.Request) {
+	// The metrics are per instance, they mustn't be cached.
+	w.Header().Set("Cache-control", "no-store")
+	metricsHandler.ServeHTTP(w, r)
+}
+
+// metricsResponseWriter records the status and size of a response.
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *metricsResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *metricsResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *metricsResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// instrument returns a handler which records the requests to route, and
+// their responses, before delegating to h.
+func instrument(route string, h http.Handler) http.Handler {
+	duration := requestDuration.WithLabelValues(route)
+	size := responseSize.WithLabelValues(route)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		mw := &metricsResponseWriter{ResponseWriter: w}
+		h.ServeHTTP(mw, r)
+		if mw.status == 0 {
+			mw.status = http.StatusOK
+		}
+		duration.Observe(time.Since(start).Seconds())
+		size.Observe(float64(mw.size))
+		requestsTotal.WithLabelValues(route, r.Method, fmt.Sprint(mw.status)).Inc()
+	})
+}
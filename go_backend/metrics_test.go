@@ -0,0 +1,62 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrument(t *testing.T) {
+	route := "/v1/test/{zoom}"
+	h := instrument(route, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			writeError(w, newBadRequestError(CodeInvalidParameter, "fail", "1", "Failed"))
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	for _, url := range []string{"/v1/test/1", "/v1/test/2", "/v1/test/3?fail=1"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	}
+	if n := testutil.ToFloat64(requestsTotal.WithLabelValues(route, "GET", "200")); n != 2 {
+		t.Errorf("Expected 2 successful requests, got %v", n)
+	}
+	if n := testutil.ToFloat64(requestsTotal.WithLabelValues(route, "GET", "400")); n != 1 {
+		t.Errorf("Expected 1 failed request, got %v", n)
+	}
+
+	w := httptest.NewRecorder()
+	metricsQuery(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, expected := range []string{
+		`election_http_request_duration_seconds_count{route="/v1/test/{zoom}"} 3`,
+		`election_http_response_size_bytes_sum{route="/v1/test/{zoom}"} `,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("Expected metrics to contain %v", expected)
+		}
+	}
+	if cc := w.Header().Get("Cache-control"); cc != "no-store" {
+		t.Errorf("Expected metrics not to be cached, got %v", cc)
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification that
+// openAPIDocument conforms to.
+const OpenAPIVersion = "3.0.3"
+
+// Schema is the subset of an OpenAPI schema object used to describe the API.
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	Default     interface{}        `json:"default,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+}
+
+// Parameter describes a path or query parameter. InvalidCode is the error
+// code returned when the parameter fails validation.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Style       string  `json:"style,omitempty"`
+	Explode     *bool   `json:"explode,omitempty"`
+	Schema      *Schema `json:"schema"`
+	Example     string  `json:"example,omitempty"`
+	InvalidCode string  `json:"-"`
+}
+
+// MediaType describes the body of a request or response in one content type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request.
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response with a given status.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Operation describes a single API endpoint.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Server is the URL the paths of an OpenAPI document are relative to.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIDocument is the root of an OpenAPI document.
+type OpenAPIDocument struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	} `json:"info"`
+	Servers    []Server                         `json:"servers"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// apiRoute is an endpoint of the API. Path is in OpenAPI syntax, Pattern is
+// the gorilla/mux pattern and defaults to Path. Method defaults to GET, which
+// also serves HEAD. RateLimit defaults to defaultRateLimit.
+type apiRoute struct {
+	Path      string
+	Pattern   string
+	Method    string
+	Handler   http.HandlerFunc
+	Operation *Operation
+	RateLimit *RateLimit
+}
+
+// newOpenAPIDocument describes routes, served under prefix.
+func newOpenAPIDocument(prefix string, routes []*apiRoute) *OpenAPIDocument {
+	doc := &OpenAPIDocument{OpenAPI: OpenAPIVersion}
+	doc.Info.Title = "Election API"
+	doc.Info.Description = "Electorate boundaries and polling places of the Australian federal election."
+	doc.Info.Version = ResponseVersion
+	doc.Servers = []Server{{URL: prefix}}
+	doc.Paths = map[string]map[string]*Operation{}
+	for _, route := range routes {
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = map[string]*Operation{}
+		}
+		method := route.Method
+		if method == "" {
+			method = "GET"
+		}
+		doc.Paths[route.Path][strings.ToLower(method)] = route.Operation
+	}
+	doc.Components.Schemas = openAPISchemas
+	return doc
+}
+
+// openAPIRoute serves the OpenAPI document of the routes it's registered
+// with.
+func openAPIRoute() *apiRoute {
+	return &apiRoute{
+		Path:      "/openapi.json",
+		RateLimit: staticRateLimit,
+		Operation: &Operation{
+			OperationID: "getOpenAPI",
+			Summary:     "This document",
+			Responses: map[string]*Response{
+				"200": {Description: "The OpenAPI document.", Content: jsonContent(&Schema{Type: "object"})},
+			},
+		},
+	}
+}
+
+// registerRoutes adds routes and their OpenAPI document to r, validating the
+// parameters of each request against its operation before calling the
+// handler. prefix is the version of the routes, for the document's server
+// URL, and mount is where r is mounted, which is the root for the legacy
+// version. Requests are rate limited by limiter, unless it's nil, before
+// responseCache, and recorded in the metrics by mount and path.
+func registerRoutes(r *mux.Router, mount, prefix string, routes []*apiRoute, limiter *rateLimiter) {
+	openAPI := openAPIRoute()
+	routes = append(routes, openAPI)
+	doc, err := json.Marshal(newOpenAPIDocument(prefix, routes))
+	if err != nil {
+		panic(err)
+	}
+	openAPI.Handler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-control", "public, max-age=120")
+		w.Header().Set("Content-type", "application/json")
+		w.Write(doc)
+	}
+	for _, route := range routes {
+		pattern := route.Pattern
+		if pattern == "" {
+			pattern = route.Path
+		}
+		h := validateParameters(route.Operation, route.Handler)
+		if responseCache != nil {
+			h = responseCache(h)
+		}
+		if limiter != nil {
+			limit := route.RateLimit
+			if limit == nil {
+				limit = defaultRateLimit
+			}
+			h = limiter.limit(limit, h)
+		}
+		rt := r.Handle(pattern, instrument(mount+route.Path, h))
+		if route.Method == "" || route.Method == "GET" {
+			rt.Methods("GET", "HEAD")
+		} else {
+			rt.Methods(route.Method)
+		}
+	}
+}
+
+// validateParameters returns a handler which checks the parameters of op
+// before delegating to h.
+func validateParameters(op *Operation, h http.Handler) http.Handler {
+	patterns := map[string]*regexp.Regexp{}
+	for _, p := range op.Parameters {
+		for _, s := range []*Schema{p.Schema, p.Schema.Items} {
+			if s != nil && s.Pattern != "" {
+				patterns[s.Pattern] = regexp.MustCompile(s.Pattern)
+			}
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range op.Parameters {
+			var value string
+			switch p.In {
+			case "path":
+				value = mux.Vars(r)[p.Name]
+			case "query":
+				value = r.URL.Query().Get(p.Name)
+			}
+			if err := p.validate(value, patterns); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (p *Parameter) validate(value string, patterns map[string]*regexp.Regexp) *APIError {
+	if value == "" {
+		if p.Required {
+			return newBadRequestError(CodeMissingParameter, p.Name, "", "%v parameter required", p.Name)
+		}
+		return nil
+	}
+	code := p.InvalidCode
+	if code == "" {
+		code = CodeInvalidParameter
+	}
+	values := []string{value}
+	schema := p.Schema
+	if schema.Type == "array" {
+		values = strings.Split(value, ",")
+		schema = schema.Items
+	}
+	for _, v := range values {
+		if expected := schema.check(v, patterns); expected != "" {
+			return newBadRequestError(code, p.Name, value, "Expected %v to be %v", p.Name, expected)
+		}
+	}
+	return nil
+}
+
+// check returns a description of the expected value if v doesn't conform
+// to s, or the empty string if it does.
+func (s *Schema) check(v string, patterns map[string]*regexp.Regexp) string {
+	if len(s.Enum) > 0 {
+		for _, e := range s.Enum {
+			if v == e {
+				return ""
+			}
+		}
+		return "one of " + strings.Join(s.Enum, ", ")
+	}
+	switch s.Type {
+	case "boolean":
+		if _, err := strconv.ParseBool(v); err != nil {
+			return "true or false"
+		}
+	case "integer":
+		i, err := strconv.Atoi(v)
+		if err != nil || (s.Minimum != nil && float64(i) < *s.Minimum) ||
+			(s.Maximum != nil && float64(i) > *s.Maximum) {
+			switch {
+			case s.Minimum != nil && s.Maximum != nil:
+				return fmt.Sprintf("an integer between %v and %v", *s.Minimum, *s.Maximum)
+			case s.Minimum != nil:
+				return fmt.Sprintf("an integer of at least %v", *s.Minimum)
+			}
+			return "an integer"
+		}
+	case "string":
+		if s.Pattern != "" && !patterns[s.Pattern].MatchString(v) {
+			return fmt.Sprintf("formatted as %v", s.Description)
+		}
+	}
+	return ""
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubRoutes returns the real operations, but handlers which don't need any
+// data loaded.
+func stubRoutes() []*apiRoute {
+	routes := apiRoutes()
+	for _, route := range routes {
+		route.Handler = func(w http.ResponseWriter, r *http.Request) {}
+	}
+	return routes
+}
+
+func TestValidateParameters(t *testing.T) {
+	r := newAPIRouter([]*apiVersion{{Prefix: "/v1", Routes: stubRoutes}}, nil)
+
+	tests := []struct {
+		url    string
+		status int
+		code   string
+		field  string
+	}{
+		{"/electorates/5?ids=all", http.StatusOK, "", ""},
+		{"/electorates/5?ids=all&format=topojson", http.StatusOK, "", ""},
+		{"/electorates/x?ids=all", http.StatusBadRequest, CodeInvalidZoom, "zoom"},
+		{"/electorates/-1?ids=all", http.StatusBadRequest, CodeInvalidZoom, "zoom"},
+		{"/electorates/5", http.StatusBadRequest, CodeMissingParameter, "ids"},
+		{"/electorates/5?ids=all&format=kml", http.StatusBadRequest, CodeInvalidFormat, "format"},
+		{"/electorates/5/export?ids=lingiari&format=gpkg", http.StatusOK, "", ""},
+		{"/electorates/5/export?ids=lingiari&format=geojson", http.StatusBadRequest, CodeInvalidFormat, "format"},
+		{"/electorates/5/export?format=shp", http.StatusBadRequest, CodeMissingParameter, "ids"},
+		{"/location?location=-12.46,130.84", http.StatusOK, "", ""},
+		{"/location?location=-12.46", http.StatusBadRequest, CodeInvalidLocation, "location"},
+		{"/location", http.StatusBadRequest, CodeMissingParameter, "location"},
+		{"/viewport/5?bbox=-13,129.5,-11.5,132", http.StatusOK, "", ""},
+		{"/viewport/5?bbox=-13,129.5,-11.5", http.StatusBadRequest, CodeInvalidBbox, "bbox"},
+		{"/viewport/5?bbox=-13,129.5,-11.5,132&include=polling_places", http.StatusOK, "", ""},
+		{"/viewport/5?bbox=-13,129.5,-11.5,132&include=polling_places,pubs", http.StatusBadRequest, CodeInvalidParameter, "include"},
+		{"/polling_places?state=TAS&wheelchair_access=Full", http.StatusOK, "", ""},
+		{"/polling_places?state=Tasmania", http.StatusBadRequest, CodeInvalidParameter, "state"},
+		{"/polling_places?bbox=-43,147,-42", http.StatusBadRequest, CodeInvalidBbox, "bbox"},
+		{"/polling_places?ids=denison&postcode=7000,hobart", http.StatusBadRequest, CodeInvalidParameter, "postcode"},
+		{"/polling_places.csv?state=TAS", http.StatusOK, "", ""},
+		{"/polling_places.kml?state=TAS&status=Closed", http.StatusBadRequest, CodeInvalidParameter, "status"},
+		{"/nearest_polling_places?location=-12.46,130.84&k=51", http.StatusBadRequest, CodeInvalidParameter, "k"},
+		{"/nearest_polling_places?location=-12.46,130.84&same_electorate=maybe", http.StatusBadRequest, CodeInvalidParameter, "same_electorate"},
+		{"/tiles/23/0/0.mvt", http.StatusBadRequest, CodeInvalidTile, "z"},
+	}
+	for _, tt := range tests {
+		// Legacy paths are validated the same as versioned paths.
+		for _, url := range []string{tt.url, "/v1" + tt.url} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+			if w.Code != tt.status {
+				t.Errorf("%v: expected status %v, got %v", url, tt.status, w.Code)
+				continue
+			}
+			if tt.status == http.StatusOK {
+				continue
+			}
+			var apiErr APIError
+			if err := json.NewDecoder(w.Body).Decode(&apiErr); err != nil {
+				t.Fatal(err)
+			}
+			if apiErr.Code != tt.code || apiErr.Field != tt.field {
+				t.Errorf("%v: expected %v for %v, got %+v", url, tt.code, tt.field, apiErr)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	r := newAPIRouter([]*apiVersion{{Prefix: "/v1", Routes: stubRoutes}}, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/v1/openapi.json", nil))
+	var doc struct {
+		OpenAPI string
+		Servers []Server
+		Paths   map[string]map[string]json.RawMessage
+	}
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != OpenAPIVersion {
+		t.Errorf("Expected openapi %v, got %v", OpenAPIVersion, doc.OpenAPI)
+	}
+	if len(doc.Servers) != 1 || doc.Servers[0].URL != "/v1" {
+		t.Errorf("Expected server /v1, got %v", doc.Servers)
+	}
+	// Every route, and the document itself.
+	paths := map[string]bool{"/openapi.json": true}
+	for _, route := range apiRoutes() {
+		paths[route.Path] = true
+	}
+	if len(doc.Paths) != len(paths) {
+		t.Errorf("Expected %v paths, got %v", len(paths), len(doc.Paths))
+	}
+	for _, method := range []string{"get", "post"} {
+		if _, ok := doc.Paths["/graphql"][method]; !ok {
+			t.Errorf("Expected %v /graphql, got %v", method, doc.Paths["/graphql"])
+		}
+	}
+	if _, ok := doc.Paths["/locations"]["post"]; !ok {
+		t.Errorf("Expected POST /locations, got %v", doc.Paths["/locations"])
+	}
+	if _, ok := doc.Paths["/electorates/{zoom}"]["get"]; !ok {
+		t.Errorf("Expected GET /electorates/{zoom}, got %v", doc.Paths["/electorates/{zoom}"])
+	}
+}
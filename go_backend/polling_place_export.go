@@ -0,0 +1,254 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Formats of polling place and electorate exports.
+const (
+	ExportCSV        = "csv"
+	ExportKML        = "kml"
+	ExportGPX        = "gpx"
+	ExportShapefile  = "shp"
+	ExportGeoPackage = "gpkg"
+)
+
+// pollingPlaceExporter writes polling places in a format for spreadsheets or
+// mapping and navigation apps.
+type pollingPlaceExporter struct {
+	ContentType string
+	Description string
+	Export      func(w io.Writer, indices []int) error
+}
+
+var pollingPlaceExporters = map[string]*pollingPlaceExporter{
+	ExportCSV: {
+		ContentType: "text/csv; charset=utf-8",
+		Description: "CSV, with a header row",
+		Export:      exportPollingPlacesCSV,
+	},
+	ExportKML: {
+		ContentType: "application/vnd.google-earth.kml+xml",
+		Description: "KML placemarks",
+		Export:      exportPollingPlacesKML,
+	},
+	ExportGPX: {
+		ContentType: "application/gpx+xml",
+		Description: "GPX waypoints",
+		Export:      exportPollingPlacesGPX,
+	},
+}
+
+// address returns the street address of p on one line, e.g. "84 George St,
+// SOUTH HURSTVILLE NSW 2221".
+func (p *PollingPlace) address() string {
+	var parts []string
+	for _, line := range []string{p.Address1, p.Address2, p.Address3} {
+		if line = strings.TrimSpace(line); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	locality := strings.TrimSpace(fmt.Sprintf("%v %v %v", p.AddressSuburb, p.AddressStateAbbreviation, p.Postcode))
+	return strings.Join(append(parts, locality), ", ")
+}
+
+// accessibility returns the wheelchair access and entrance details of p, one
+// per line, as shown to voters.
+func (p *PollingPlace) accessibility() []string {
+	lines := []string{"Wheelchair access: " + p.WheelchairAccess}
+	for _, detail := range []struct{ label, value string }{
+		{"Entrances", p.EntrancesDescription},
+		{"Booth location", p.AdviceBoothLocation},
+		{"Gate access", p.AdviceGateAccess},
+	} {
+		if value := strings.TrimSpace(detail.value); value != "" {
+			lines = append(lines, detail.label+": "+value)
+		}
+	}
+	return lines
+}
+
+var pollingPlaceCSVHeader = []string{
+	"Polling place ID", "Name", "Premises", "Address", "Suburb", "State", "Postcode",
+	"Electorate", "Status", "Wheelchair access", "Entrances", "Booth location",
+	"Gate access", "Latitude", "Longitude",
+}
+
+func exportPollingPlacesCSV(w io.Writer, indices []int) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(pollingPlaceCSVHeader); err != nil {
+		return err
+	}
+	for _, i := range indices {
+		p := &pollingPlaces[i]
+		err := cw.Write([]string{
+			strconv.Itoa(p.PollingPlaceId),
+			p.PrettyPrintName,
+			p.PremisesName,
+			p.address(),
+			p.AddressSuburb,
+			p.AddressStateAbbreviation,
+			strconv.Itoa(p.Postcode),
+			p.DivisionName,
+			p.Status,
+			p.WheelchairAccess,
+			strings.TrimSpace(p.EntrancesDescription),
+			strings.TrimSpace(p.AdviceBoothLocation),
+			strings.TrimSpace(p.AdviceGateAccess),
+			strconv.FormatFloat(p.Lat, 'f', -1, 64),
+			strconv.FormatFloat(p.Lng, 'f', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlBoundary struct {
+	Coordinates string `xml:"LinearRing>coordinates"`
+}
+
+type kmlPolygon struct {
+	Outer kmlBoundary   `xml:"outerBoundaryIs"`
+	Inner []kmlBoundary `xml:"innerBoundaryIs"`
+}
+
+type kmlPlacemark struct {
+	ID            string       `xml:"id,attr,omitempty"`
+	Name          string       `xml:"name"`
+	Address       string       `xml:"address,omitempty"`
+	Description   string       `xml:"description,omitempty"`
+	ExtendedData  []kmlData    `xml:"ExtendedData>Data,omitempty"`
+	Point         *kmlPoint    `xml:"Point,omitempty"`
+	MultiGeometry []kmlPolygon `xml:"MultiGeometry>Polygon,omitempty"`
+}
+
+type kml struct {
+	XMLName    xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+// writeXML writes v as an XML document.
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func exportPollingPlacesKML(w io.Writer, indices []int) error {
+	doc := &kml{Name: "Polling places"}
+	for _, i := range indices {
+		p := &pollingPlaces[i]
+		doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+			ID:          fmt.Sprintf("pp%v", p.PollingPlaceId),
+			Name:        p.PrettyPrintName,
+			Address:     p.address(),
+			Description: strings.Join(append([]string{p.PremisesName, p.address()}, p.accessibility()...), "\n"),
+			ExtendedData: []kmlData{
+				{"pollingPlaceId", strconv.Itoa(p.PollingPlaceId)},
+				{"electorate", p.DivisionName},
+				{"status", p.Status},
+				{"wheelchairAccess", p.WheelchairAccess},
+			},
+			Point: &kmlPoint{Coordinates: fmt.Sprintf("%v,%v", p.Lng, p.Lat)},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+type gpxWaypoint struct {
+	Lat         float64 `xml:"lat,attr"`
+	Lon         float64 `xml:"lon,attr"`
+	Name        string  `xml:"name"`
+	Description string  `xml:"desc,omitempty"`
+	Type        string  `xml:"type"`
+}
+
+type gpx struct {
+	XMLName   xml.Name      `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+}
+
+func exportPollingPlacesGPX(w io.Writer, indices []int) error {
+	doc := &gpx{Version: "1.1", Creator: "election-au-2016"}
+	for _, i := range indices {
+		p := &pollingPlaces[i]
+		doc.Waypoints = append(doc.Waypoints, gpxWaypoint{
+			Lat:         p.Lat,
+			Lon:         p.Lng,
+			Name:        p.PrettyPrintName,
+			Description: strings.Join(append([]string{p.PremisesName, p.address()}, p.accessibility()...), "\n"),
+			Type:        "Polling place",
+		})
+	}
+	return writeXML(w, doc)
+}
+
+// pollingPlacesExportQuery writes the polling places /polling_places would
+// return, without polling place groups, as an attachment in the format of its
+// extension.
+func pollingPlacesExportQuery(ext string) http.HandlerFunc {
+	exporter := pollingPlaceExporters[ext]
+	return func(w http.ResponseWriter, r *http.Request) {
+		ids, filter, err := parsePollingPlaceSelection(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		indices, err := selectPollingPlaces(ids, filter)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Cache-control", "public, max-age=120")
+		w.Header().Set("Content-type", exporter.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="polling_places.%v"`, ext))
+		if err := exporter.Export(w, indices); err != nil {
+			writeError(w, err)
+		}
+	}
+}
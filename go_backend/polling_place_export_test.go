@@ -0,0 +1,85 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestPollingPlaceAddress(t *testing.T) {
+	p := PollingPlace{
+		Address1:                 "84 George St",
+		Address2:                 " ",
+		AddressSuburb:            "SOUTH HURSTVILLE",
+		AddressStateAbbreviation: "NSW",
+		Postcode:                 2221,
+	}
+	if expected := "84 George St, SOUTH HURSTVILLE NSW 2221"; p.address() != expected {
+		t.Errorf("Expected %q, got %q", expected, p.address())
+	}
+}
+
+func TestExportPollingPlaces(t *testing.T) {
+	indices := []int{0, 1}
+
+	var b bytes.Buffer
+	if err := exportPollingPlacesCSV(&b, indices); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != len(indices)+1 {
+		t.Fatalf("Expected a header and %v rows, got %v", len(indices), records)
+	}
+	if records[1][1] != pollingPlaces[0].PrettyPrintName || records[1][3] != pollingPlaces[0].address() {
+		t.Errorf("Expected %v, got %v", pollingPlaces[0].PrettyPrintName, records[1])
+	}
+
+	b.Reset()
+	if err := exportPollingPlacesKML(&b, indices); err != nil {
+		t.Fatal(err)
+	}
+	var k kml
+	if err := xml.Unmarshal(b.Bytes(), &k); err != nil {
+		t.Fatal(err)
+	}
+	if len(k.Placemarks) != len(indices) || k.Placemarks[1].Name != pollingPlaces[1].PrettyPrintName {
+		t.Errorf("Expected %v placemarks, got %+v", len(indices), k.Placemarks)
+	}
+	if !strings.Contains(k.Placemarks[0].Description, "Wheelchair access: "+pollingPlaces[0].WheelchairAccess) {
+		t.Errorf("Expected wheelchair access in %q", k.Placemarks[0].Description)
+	}
+
+	b.Reset()
+	if err := exportPollingPlacesGPX(&b, indices); err != nil {
+		t.Fatal(err)
+	}
+	var g gpx
+	if err := xml.Unmarshal(b.Bytes(), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Waypoints) != len(indices) || g.Waypoints[0].Lat != pollingPlaces[0].Lat || g.Waypoints[0].Lon != pollingPlaces[0].Lng {
+		t.Errorf("Expected %v waypoints, got %+v", len(indices), g.Waypoints)
+	}
+}
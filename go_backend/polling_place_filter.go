@@ -0,0 +1,135 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	rtree "github.com/dhconnelly/rtreego"
+)
+
+// Values of the polling place attributes which can be filtered on.
+var (
+	pollingPlaceStates           = []string{"ACT", "NSW", "NT", "QLD", "SA", "TAS", "VIC", "WA"}
+	pollingPlaceWheelchairAccess = []string{"Full", "Assisted", "None"}
+	pollingPlaceStatuses         = []string{"Current", "Appointment"}
+)
+
+// pollingPlaceFilter selects polling places by their attributes and
+// location. Empty sets match everything.
+type pollingPlaceFilter struct {
+	states                  map[string]bool
+	wheelchairAccess        map[string]bool
+	statuses                map[string]bool
+	postcodes               map[int]bool
+	minOrdinaryVoteEstimate int
+	rect                    *rtree.Rect
+}
+
+// isEmpty returns true if the filter matches every polling place.
+func (f *pollingPlaceFilter) isEmpty() bool {
+	return len(f.states) == 0 && len(f.wheelchairAccess) == 0 && len(f.statuses) == 0 &&
+		len(f.postcodes) == 0 && f.minOrdinaryVoteEstimate == 0 && f.rect == nil
+}
+
+func (f *pollingPlaceFilter) matches(p *PollingPlace) bool {
+	return (len(f.states) == 0 || f.states[p.StateAbbreviation]) &&
+		(len(f.wheelchairAccess) == 0 || f.wheelchairAccess[p.WheelchairAccess]) &&
+		(len(f.statuses) == 0 || f.statuses[p.Status]) &&
+		(len(f.postcodes) == 0 || f.postcodes[p.Postcode]) &&
+		p.OrdinaryVoteEstimate >= f.minOrdinaryVoteEstimate &&
+		(f.rect == nil || rectContains(f.rect, p.Lng, p.Lat))
+}
+
+// parseSet parses s, the comma separated parameter name, whose values must
+// be in allowed.
+func parseSet(name, s string, allowed []string) (map[string]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return newCheckedSet(name, s, strings.Split(s, ","), allowed)
+}
+
+// newCheckedSet returns the set of values of the parameter name, given as s,
+// which must be in allowed.
+func newCheckedSet(name, s string, values []string, allowed []string) (map[string]bool, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	set := map[string]bool{}
+	for _, v := range values {
+		ok := false
+		for _, a := range allowed {
+			ok = ok || v == a
+		}
+		if !ok {
+			return nil, newBadRequestError(CodeInvalidParameter, name, s,
+				"Expected %v to be one of %v", name, strings.Join(allowed, ", "))
+		}
+		set[v] = true
+	}
+	return set, nil
+}
+
+// parsePollingPlaceFilter parses the filter parameters of /polling_places.
+func parsePollingPlaceFilter(r *http.Request) (*pollingPlaceFilter, error) {
+	return newPollingPlaceFilter(r.FormValue)
+}
+
+// newPollingPlaceFilter parses the filter parameters of /polling_places,
+// whose values are returned by param. The gRPC and GraphQL APIs give their
+// filters as these parameters, so that they're checked the same way.
+func newPollingPlaceFilter(param func(name string) string) (*pollingPlaceFilter, error) {
+	var f pollingPlaceFilter
+	var err error
+	if f.states, err = parseSet("state", param("state"), pollingPlaceStates); err != nil {
+		return nil, err
+	}
+	if f.wheelchairAccess, err = parseSet("wheelchair_access", param("wheelchair_access"), pollingPlaceWheelchairAccess); err != nil {
+		return nil, err
+	}
+	if f.statuses, err = parseSet("status", param("status"), pollingPlaceStatuses); err != nil {
+		return nil, err
+	}
+	if s := param("postcode"); s != "" {
+		f.postcodes = map[int]bool{}
+		for _, v := range strings.Split(s, ",") {
+			postcode, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, newBadRequestError(CodeInvalidParameter, "postcode", s,
+					"Expected postcode to be comma separated integers")
+			}
+			f.postcodes[postcode] = true
+		}
+	}
+	if s := param("min_ordinary_vote_estimate"); s != "" {
+		f.minOrdinaryVoteEstimate, err = strconv.Atoi(s)
+		if err != nil || f.minOrdinaryVoteEstimate < 0 {
+			return nil, newBadRequestError(CodeInvalidParameter, "min_ordinary_vote_estimate", s,
+				"Expected min_ordinary_vote_estimate to be a non-negative integer")
+		}
+	}
+	if bbox := param("bbox"); bbox != "" {
+		if f.rect, err = ParseBboxToRect(bbox); err != nil {
+			return nil, newBadRequestError(CodeInvalidBbox, "bbox", bbox, "Invalid bbox: %v", err)
+		}
+	}
+	return &f, nil
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPollingPlaceFilter(t *testing.T) {
+	hobart := PollingPlace{
+		StateAbbreviation:    "TAS",
+		Status:               "Current",
+		Postcode:             7000,
+		Lat:                  -42.8821,
+		Lng:                  147.3272,
+		WheelchairAccess:     "Full",
+		OrdinaryVoteEstimate: 1200,
+	}
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"", true},
+		{"state=TAS&wheelchair_access=Full", true},
+		{"state=NSW,VIC", false},
+		{"wheelchair_access=Assisted,None", false},
+		{"status=Appointment", false},
+		{"postcode=7000,7001", true},
+		{"postcode=2000", false},
+		{"min_ordinary_vote_estimate=1200", true},
+		{"min_ordinary_vote_estimate=1201", false},
+		{"bbox=-43,147,-42,148", true},
+		{"bbox=-34,151,-33,152", false},
+	}
+	for _, tt := range tests {
+		f, err := parsePollingPlaceFilter(httptest.NewRequest("GET", "/polling_places?"+tt.query, nil))
+		if err != nil {
+			t.Fatalf("%v: %v", tt.query, err)
+		}
+		if f.matches(&hobart) != tt.expected {
+			t.Errorf("%v: expected match %v", tt.query, tt.expected)
+		}
+		if f.isEmpty() != (tt.query == "") {
+			t.Errorf("%v: expected isEmpty %v", tt.query, tt.query == "")
+		}
+	}
+	for _, query := range []string{"state=Tasmania", "status=current", "postcode=hobart", "min_ordinary_vote_estimate=-1"} {
+		if _, err := parsePollingPlaceFilter(httptest.NewRequest("GET", "/polling_places?"+query, nil)); err == nil {
+			t.Errorf("%v: expected an error", query)
+		}
+	}
+}
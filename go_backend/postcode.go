@@ -0,0 +1,334 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	shp "github.com/jonas-p/go-shp"
+)
+
+// PostcodeDataFolder is where ABS postal area (POA) shapefiles may be put.
+// They're optional: without them, the electorates of a postcode are
+// estimated from its polling places only.
+const PostcodeDataFolder = "dist/postcodes"
+
+// Sources of coverage estimates.
+const (
+	CoverageFromPostcodeBoundaries = "postcode_boundaries"
+	CoverageFromPollingPlaces      = "polling_places"
+)
+
+// postcodeSamples is the number of points along each side of a grid over a
+// postcode's bounding box, used to estimate which share of its area is in
+// each electorate.
+const postcodeSamples = 24
+
+// ElectorateCandidate is an electorate which may be the one of a postcode or
+// suburb. Coverage is the estimated share, between 0 and 1, of the area or
+// voters in the electorate. PollingPlaces is the number of polling places of
+// the postcode or suburb in the electorate.
+type ElectorateCandidate struct {
+	ElectorateID  ElectorateID
+	Name          string
+	State         string
+	Coverage      float64
+	PollingPlaces int
+}
+
+// AreaElectorates is the response of /postcode/{pc} and /suburb/{name}. As
+// many postcodes and suburbs straddle electorates, every candidate is
+// returned, most likely first.
+type AreaElectorates struct {
+	Postcode    string `json:",omitempty"`
+	Suburb      string `json:",omitempty"`
+	Source      string
+	Electorates []ElectorateCandidate
+}
+
+// areaTally counts the polling places of a postcode or suburb by electorate.
+type areaTally map[ElectorateID]*ElectorateCandidate
+
+type byCoverage []ElectorateCandidate
+
+func (c byCoverage) Len() int      { return len(c) }
+func (c byCoverage) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byCoverage) Less(i, j int) bool {
+	if c[i].Coverage != c[j].Coverage {
+		return c[i].Coverage > c[j].Coverage
+	}
+	return c[i].ElectorateID < c[j].ElectorateID
+}
+
+// candidates returns the electorates of t, weighted by the estimated number
+// of ordinary votes at their polling places (or the number of polling
+// places, if there are no estimates), which is a rough proxy for population.
+func (t areaTally) candidates(votes map[ElectorateID]int) []ElectorateCandidate {
+	total := 0
+	for _, v := range votes {
+		total += v
+	}
+	places := 0
+	for _, c := range t {
+		places += c.PollingPlaces
+	}
+	var candidates []ElectorateCandidate
+	for id, c := range t {
+		candidate := *c
+		if total > 0 {
+			candidate.Coverage = float64(votes[id]) / float64(total)
+		} else {
+			candidate.Coverage = float64(c.PollingPlaces) / float64(places)
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Sort(byCoverage(candidates))
+	return candidates
+}
+
+type areaIndex struct {
+	tallies map[string]areaTally
+	votes   map[string]map[ElectorateID]int
+}
+
+func newAreaIndex() *areaIndex {
+	return &areaIndex{tallies: map[string]areaTally{}, votes: map[string]map[ElectorateID]int{}}
+}
+
+func (idx *areaIndex) add(key string, e *Electorate, p *PollingPlace) {
+	if idx.tallies[key] == nil {
+		idx.tallies[key] = areaTally{}
+		idx.votes[key] = map[ElectorateID]int{}
+	}
+	c := idx.tallies[key][e.id]
+	if c == nil {
+		c = &ElectorateCandidate{ElectorateID: e.id, Name: e.name, State: e.state}
+		idx.tallies[key][e.id] = c
+	}
+	c.PollingPlaces++
+	idx.votes[key][e.id] += p.OrdinaryVoteEstimate
+}
+
+func (idx *areaIndex) candidates(key string) []ElectorateCandidate {
+	t, ok := idx.tallies[key]
+	if !ok {
+		return nil
+	}
+	return t.candidates(idx.votes[key])
+}
+
+var (
+	postcodeIndex *areaIndex
+	suburbIndex   *areaIndex
+	// postcodeBoundaries are the polygons of each postcode, if the ABS
+	// shapefiles are available.
+	postcodeBoundaries map[int][]*shp.Polygon
+	// postcodeCoverage caches the coverage estimated from
+	// postcodeBoundaries.
+	postcodeCoverage   = map[int][]ElectorateCandidate{}
+	postcodeCoverageMu sync.Mutex
+)
+
+// normalizeSuburb returns the name of a suburb as in the AEC data.
+func normalizeSuburb(name string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(name)), " ")
+}
+
+// suburbKey is the key of a suburb in suburbIndex, which has every suburb
+// both with and without its state.
+func suburbKey(suburb, state string) string {
+	if state == "" {
+		return suburb
+	}
+	return suburb + ";" + strings.ToUpper(state)
+}
+
+// resolvePollingPlaceElectorate returns the electorate containing p, or the
+// one it's listed under for polling places outside any electorate.
+func resolvePollingPlaceElectorate(p *PollingPlace) *Electorate {
+	if e := locateElectorate(p.Lng, p.Lat); e != nil {
+		return e
+	}
+	return electorates[ElectorateID(strings.ToLower(p.DivisionName))]
+}
+
+func initAreaIndexes() {
+	postcodeIndex = newAreaIndex()
+	suburbIndex = newAreaIndex()
+	for i := range pollingPlaces {
+		p := &pollingPlaces[i]
+		e := resolvePollingPlaceElectorate(p)
+		if e == nil {
+			continue
+		}
+		if p.Postcode > 0 {
+			postcodeIndex.add(strconv.Itoa(p.Postcode), e, p)
+		}
+		if p.AddressSuburb != "" {
+			suburb := normalizeSuburb(p.AddressSuburb)
+			suburbIndex.add(suburbKey(suburb, ""), e, p)
+			suburbIndex.add(suburbKey(suburb, p.AddressStateAbbreviation), e, p)
+		}
+	}
+	postcodeBoundaries = loadPostcodeBoundaries()
+}
+
+// loadPostcodeBoundaries loads the polygons of the shapefiles in
+// PostcodeDataFolder, if any.
+func loadPostcodeBoundaries() map[int][]*shp.Polygon {
+	filenames, err := filepath.Glob(filepath.Join(PostcodeDataFolder, "*.shp"))
+	if err != nil || len(filenames) == 0 {
+		return nil
+	}
+	boundaries := map[int][]*shp.Polygon{}
+	for _, filename := range filenames {
+		if err := loadPostcodes(filename, boundaries); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Printf("Postcode map has %v entries\n", len(boundaries))
+	return boundaries
+}
+
+func loadPostcodes(filename string, boundaries map[int][]*shp.Polygon) error {
+	r, err := shp.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	// The postcode field is named after the ABS release, e.g. POA_CODE16.
+	field := -1
+	for k, f := range r.Fields() {
+		name := strings.ToUpper(readZeroTerminatedString(string(f.Name[:])))
+		if strings.HasPrefix(name, "POA_CODE") || name == "POSTCODE" {
+			field = k
+			break
+		}
+	}
+	if field < 0 {
+		return fmt.Errorf("%v: expected a POA_CODE or POSTCODE field", filename)
+	}
+	for r.Next() {
+		index, shape := r.Shape()
+		polygon, ok := shape.(*shp.Polygon)
+		if !ok {
+			continue
+		}
+		postcode, err := strconv.Atoi(strings.TrimSpace(readZeroTerminatedString(r.ReadAttribute(index, field))))
+		if err != nil {
+			// e.g. the "no usual address" areas.
+			continue
+		}
+		boundaries[postcode] = append(boundaries[postcode], polygon)
+	}
+	return nil
+}
+
+// boundaryCoverage estimates the share of the area of the postcode in each
+// electorate, by locating a grid of points in its bounding box.
+func boundaryCoverage(postcode int, polygons []*shp.Polygon) []ElectorateCandidate {
+	postcodeCoverageMu.Lock()
+	cached, ok := postcodeCoverage[postcode]
+	postcodeCoverageMu.Unlock()
+	if ok {
+		return cached
+	}
+	bbox := polygons[0].BBox()
+	for _, p := range polygons[1:] {
+		bbox.Extend(p.BBox())
+	}
+	t := areaTally{}
+	samples := map[ElectorateID]int{}
+	for i := 0; i < postcodeSamples; i++ {
+		for j := 0; j < postcodeSamples; j++ {
+			pt := shp.Point{
+				X: bbox.MinX + (float64(i)+0.5)*(bbox.MaxX-bbox.MinX)/postcodeSamples,
+				Y: bbox.MinY + (float64(j)+0.5)*(bbox.MaxY-bbox.MinY)/postcodeSamples,
+			}
+			in := false
+			for _, p := range polygons {
+				if in = inside(pt, *p); in {
+					break
+				}
+			}
+			if !in {
+				continue
+			}
+			e := locateElectorate(pt.X, pt.Y)
+			if e == nil {
+				continue
+			}
+			if t[e.id] == nil {
+				t[e.id] = &ElectorateCandidate{ElectorateID: e.id, Name: e.name, State: e.state}
+			}
+			samples[e.id]++
+		}
+	}
+	// Polling place counts come from the polling place index.
+	for _, c := range postcodeIndex.candidates(strconv.Itoa(postcode)) {
+		if t[c.ElectorateID] != nil {
+			t[c.ElectorateID].PollingPlaces = c.PollingPlaces
+		}
+	}
+	candidates := t.candidates(samples)
+	postcodeCoverageMu.Lock()
+	postcodeCoverage[postcode] = candidates
+	postcodeCoverageMu.Unlock()
+	return candidates
+}
+
+// queryPostcode returns the candidate electorates of a postcode.
+func queryPostcode(pc string) (*AreaElectorates, error) {
+	postcode, err := strconv.Atoi(pc)
+	if err != nil {
+		return nil, newBadRequestError(CodeInvalidParameter, "pc", pc, "Expected pc to be a postcode")
+	}
+	response := &AreaElectorates{Postcode: fmt.Sprintf("%04d", postcode)}
+	if polygons, ok := postcodeBoundaries[postcode]; ok {
+		response.Source = CoverageFromPostcodeBoundaries
+		response.Electorates = boundaryCoverage(postcode, polygons)
+	}
+	if len(response.Electorates) == 0 {
+		response.Source = CoverageFromPollingPlaces
+		response.Electorates = postcodeIndex.candidates(strconv.Itoa(postcode))
+	}
+	if len(response.Electorates) == 0 {
+		return nil, newUnknownAreaError(CodeUnknownPostcode, "pc", pc)
+	}
+	return response, nil
+}
+
+// querySuburb returns the candidate electorates of a suburb, in the given
+// state if it isn't empty, as many suburb names are used in several states.
+func querySuburb(name, state string) (*AreaElectorates, error) {
+	response := &AreaElectorates{
+		Suburb: normalizeSuburb(name),
+		Source: CoverageFromPollingPlaces,
+	}
+	response.Electorates = suburbIndex.candidates(suburbKey(response.Suburb, state))
+	if len(response.Electorates) == 0 {
+		return nil, newUnknownAreaError(CodeUnknownSuburb, "name", name)
+	}
+	return response, nil
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import "testing"
+
+func TestAreaIndexCandidates(t *testing.T) {
+	banks := &Electorate{id: "banks", name: "Banks", state: "NSW"}
+	barton := &Electorate{id: "barton", name: "Barton", state: "NSW"}
+	idx := newAreaIndex()
+	idx.add("2220", banks, &PollingPlace{OrdinaryVoteEstimate: 1000})
+	idx.add("2220", barton, &PollingPlace{OrdinaryVoteEstimate: 2000})
+	idx.add("2220", barton, &PollingPlace{OrdinaryVoteEstimate: 1000})
+	candidates := idx.candidates("2220")
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %v", candidates)
+	}
+	if c := candidates[0]; c.ElectorateID != "barton" || c.Coverage != 0.75 || c.PollingPlaces != 2 {
+		t.Errorf("Expected Barton first with 0.75 coverage, got %+v", c)
+	}
+	if c := candidates[1]; c.ElectorateID != "banks" || c.Coverage != 0.25 {
+		t.Errorf("Expected Banks second with 0.25 coverage, got %+v", c)
+	}
+	if candidates := idx.candidates("2221"); candidates != nil {
+		t.Errorf("Expected no candidates for an unknown postcode, got %v", candidates)
+	}
+}
+
+func TestAreaIndexCandidatesWithoutVotes(t *testing.T) {
+	banks := &Electorate{id: "banks", name: "Banks", state: "NSW"}
+	barton := &Electorate{id: "barton", name: "Barton", state: "NSW"}
+	idx := newAreaIndex()
+	for _, e := range []*Electorate{banks, banks, banks, barton} {
+		idx.add("OATLEY", e, &PollingPlace{})
+	}
+	candidates := idx.candidates("OATLEY")
+	if len(candidates) != 2 || candidates[0].Coverage != 0.75 || candidates[1].Coverage != 0.25 {
+		t.Errorf("Expected coverage by number of polling places, got %+v", candidates)
+	}
+}
+
+func TestSuburbKey(t *testing.T) {
+	if k := suburbKey(normalizeSuburb(" oatley  west"), "nsw"); k != "OATLEY WEST;NSW" {
+		t.Errorf("Unexpected suburb key %q", k)
+	}
+	if k := suburbKey(normalizeSuburb("Oatley"), ""); k != "OATLEY" {
+		t.Errorf("Unexpected suburb key %q", k)
+	}
+}
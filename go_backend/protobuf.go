@@ -0,0 +1,79 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+// Minimal protobuf encoding helpers, shared by the hand written vector tile
+// and geobuf encoders.
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendKey(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field<<3|wireType))
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = appendKey(b, field, wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendKey(b, field, wireVarint)
+	return appendVarint(b, v)
+}
+
+func appendFixed64Field(b []byte, field int, v uint64) []byte {
+	b = appendKey(b, field, wireFixed64)
+	for i := uint(0); i < 8; i++ {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
+
+func appendPackedField(b []byte, field int, vs []uint32) []byte {
+	var packed []byte
+	for _, v := range vs {
+		packed = appendVarint(packed, uint64(v))
+	}
+	return appendBytesField(b, field, packed)
+}
+
+func appendPackedSint64Field(b []byte, field int, vs []int64) []byte {
+	var packed []byte
+	for _, v := range vs {
+		packed = appendVarint(packed, zigzag64(v))
+	}
+	return appendBytesField(b, field, packed)
+}
+
+func zigzag64(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
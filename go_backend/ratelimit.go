@@ -0,0 +1,194 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit configures the token buckets of a route: each client may make
+// Burst requests at once, refilled at Rate requests per second. Routes with
+// the same Name share a bucket per client. Cost, if set, returns how many
+// tokens a request takes, for requests which are more expensive than others
+// of the same route.
+type RateLimit struct {
+	Name  string
+	Rate  float64
+	Burst float64
+	Cost  func(r *http.Request) float64
+}
+
+// Rate limits of the routes. Map clients fetch many tiles and zoom buckets
+// are static, while scrapers looping over locations compete with real users
+// on election night.
+var (
+	defaultRateLimit  = &RateLimit{Name: "default", Rate: 10, Burst: 100}
+	staticRateLimit   = &RateLimit{Name: "static", Rate: 50, Burst: 200}
+	tileRateLimit     = &RateLimit{Name: "tiles", Rate: 50, Burst: 400}
+	locationRateLimit = &RateLimit{Name: "location", Rate: 5, Burst: 50}
+	// Each term of a search is compared with every indexed term.
+	searchRateLimit = &RateLimit{Name: "search", Rate: 5, Burst: 50}
+	// Each request may have up to MaxBatchLocations locations.
+	locationsRateLimit   = &RateLimit{Name: "locations", Rate: 0.1, Burst: 3}
+	electoratesRateLimit = &RateLimit{
+		Name:  "electorates",
+		Rate:  10,
+		Burst: 100,
+		Cost: func(r *http.Request) float64 {
+			if strings.ToLower(r.URL.Query().Get("ids")) == "all" {
+				return AllElectoratesCost
+			}
+			return 1
+		},
+	}
+	// Each query may do the work of several REST requests, so queries
+	// cost more the more fields they resolve.
+	graphQLRateLimit = &RateLimit{Name: "graphql", Rate: 2, Burst: 40, Cost: graphQLCost}
+)
+
+// AllElectoratesCost is the number of tokens taken by a request for the
+// geometry of all electorates.
+const AllElectoratesCost = 20
+
+// APIKeysEnv is the environment variable with the comma separated API keys
+// of partners. Requests with one of these in the X-API-Key header, or the
+// key parameter, are limited per key rather than per IP address, so that
+// partners behind shared addresses aren't limited by their neighbours.
+const APIKeysEnv = "ELECTION_API_KEYS"
+
+// APIKeyHeader is the header requests may give an API key in.
+const APIKeyHeader = "X-API-Key"
+
+// rateLimitSweepInterval is how often buckets which have refilled, and so are
+// the same as no bucket, are removed.
+const rateLimitSweepInterval = time.Minute
+
+type tokenBucket struct {
+	limit  *RateLimit
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens accrued since the bucket was last used.
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.limit.Burst, b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate)
+	b.last = now
+}
+
+// rateLimiter holds the token buckets of every client. Each App Engine
+// instance has its own, so the limits are per instance.
+type rateLimiter struct {
+	apiKeys   map[string]bool
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(apiKeys []string) *rateLimiter {
+	l := &rateLimiter{
+		apiKeys: map[string]bool{},
+		now:     time.Now,
+		buckets: map[string]*tokenBucket{},
+	}
+	for _, key := range apiKeys {
+		if key = strings.TrimSpace(key); key != "" {
+			l.apiKeys[key] = true
+		}
+	}
+	return l
+}
+
+func newRateLimiterFromEnv() *rateLimiter {
+	return newRateLimiter(strings.Split(os.Getenv(APIKeysEnv), ","))
+}
+
+// client identifies the client of r by its API key, if it's a known one, or
+// otherwise its IP address.
+func (l *rateLimiter) client(r *http.Request) string {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get("key")
+	}
+	if l.apiKeys[key] {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// take takes cost tokens from the client's bucket for limit. If there aren't
+// enough, it returns how long until there will be.
+func (l *rateLimiter) take(client string, limit *RateLimit, cost float64) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		for k, b := range l.buckets {
+			if b.refill(now); b.tokens >= b.limit.Burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	k := limit.Name + ";" + client
+	b := l.buckets[k]
+	if b == nil {
+		b = &tokenBucket{limit: limit, tokens: limit.Burst, last: now}
+		l.buckets[k] = b
+	}
+	b.refill(now)
+	if b.tokens < cost {
+		return false, time.Duration((cost - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	b.tokens -= cost
+	return true, 0
+}
+
+// limit returns a handler which rejects requests of clients which exceed
+// limit before delegating to h.
+func (l *rateLimiter) limit(limit *RateLimit, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cost := 1.0
+		if limit.Cost != nil {
+			cost = limit.Cost(r)
+		}
+		ok, retryAfter := l.take(l.client(r), limit, cost)
+		if !ok {
+			seconds := int(math.Max(1, math.Ceil(retryAfter.Seconds())))
+			w.Header().Set("Retry-After", fmt.Sprint(seconds))
+			writeError(w, &APIError{
+				Status:  http.StatusTooManyRequests,
+				Code:    CodeRateLimited,
+				Message: fmt.Sprintf("Too many requests, retry after %v seconds", seconds),
+			})
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
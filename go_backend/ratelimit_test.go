@@ -0,0 +1,125 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	now := time.Unix(1467331200, 0)
+	l := newRateLimiter(nil)
+	l.now = func() time.Time { return now }
+	limit := &RateLimit{Name: "test", Rate: 2, Burst: 3}
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.take("ip:192.0.2.1", limit, 1); !ok {
+			t.Fatalf("Request %v: expected to be allowed within the burst", i)
+		}
+	}
+	ok, retryAfter := l.take("ip:192.0.2.1", limit, 1)
+	if ok {
+		t.Fatal("Expected the request after the burst to be limited")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("Expected to retry after 500ms, got %v", retryAfter)
+	}
+	if ok, _ := l.take("ip:192.0.2.2", limit, 1); !ok {
+		t.Error("Expected other clients to have their own bucket")
+	}
+	now = now.Add(time.Second)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.take("ip:192.0.2.1", limit, 1); !ok {
+			t.Errorf("Request %v: expected to be allowed after refilling", i)
+		}
+	}
+	if ok, _ := l.take("ip:192.0.2.1", limit, 1); ok {
+		t.Error("Expected the bucket to refill at the rate")
+	}
+}
+
+func TestRateLimiterHandler(t *testing.T) {
+	l := newRateLimiter([]string{"partner"})
+	limit := &RateLimit{
+		Name:  "test",
+		Rate:  0.1,
+		Burst: 2,
+		Cost: func(r *http.Request) float64 {
+			if r.URL.Query().Get("ids") == "all" {
+				return 2
+			}
+			return 1
+		},
+	}
+	h := l.limit(limit, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		url    string
+		apiKey string
+		status int
+	}{
+		{"/electorates/5?ids=all", "", http.StatusOK},
+		{"/electorates/5?ids=lingiari", "", http.StatusTooManyRequests},
+		// Unknown keys are limited by IP address.
+		{"/electorates/5?ids=lingiari", "scraper", http.StatusTooManyRequests},
+		{"/electorates/5?ids=lingiari", "partner", http.StatusOK},
+		{"/electorates/5?ids=lingiari&key=partner", "", http.StatusOK},
+		{"/electorates/5?ids=lingiari", "partner", http.StatusTooManyRequests},
+	}
+	for i, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if tt.apiKey != "" {
+			r.Header.Set(APIKeyHeader, tt.apiKey)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%v: expected status %v, got %v", i, tt.status, w.Code)
+		}
+		if tt.status == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "10" {
+			t.Errorf("%v: expected Retry-After 10, got %v", i, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestRateLimiterCachedResponses(t *testing.T) {
+	defer func() { responseCache = nil }()
+	hits := 0
+	// A cache which always hits.
+	CacheResponses(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			w.Write([]byte("[]"))
+		})
+	})
+	l := newRateLimiter(nil)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	r := newAPIRouter([]*apiVersion{{Prefix: "/v1", Routes: stubRoutes}}, l)
+	for i := 0; i <= int(staticRateLimit.Burst); i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/v1/zoom_buckets", nil))
+		if w.Code == http.StatusTooManyRequests {
+			break
+		}
+	}
+	if hits != int(staticRateLimit.Burst) {
+		t.Errorf("Expected %v cached responses before the limit, got %v", staticRateLimit.Burst, hits)
+	}
+}
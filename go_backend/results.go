@@ -0,0 +1,365 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResultsKeyEnv is the environment variable with the key which POST /results
+// requires in the X-API-Key header. Results can't be ingested if it's unset.
+const ResultsKeyEnv = "ELECTION_RESULTS_KEY"
+
+// MaxResultsBodyBytes is the largest results update accepted, enough for
+// the nation and every electorate.
+const MaxResultsBodyBytes = 8 << 20
+
+// MaxResultsEvents is the number of events kept for clients resuming a
+// stream with Last-Event-ID. Clients which missed more are sent the current
+// results instead.
+const MaxResultsEvents = 1000
+
+// ResultsStreamDuration is how long a results stream stays open. Clients
+// reconnect with Last-Event-ID, so this only bounds how long a connection is
+// held.
+const ResultsStreamDuration = 50 * time.Second
+
+// ResultsStreamRetry is the reconnection delay sent to clients.
+const ResultsStreamRetry = time.Second
+
+// ResultsStreamHeartbeat is how often a comment is sent on an idle stream,
+// so that proxies don't close it.
+const ResultsStreamHeartbeat = 15 * time.Second
+
+// Types of results events, which are the SSE event names.
+const (
+	ResultsEventNation     = "nation"
+	ResultsEventElectorate = "electorate"
+)
+
+// VoteSummary is a number of votes and its percentage of the formal votes.
+type VoteSummary struct {
+	Percentage float64
+	Total      int
+}
+
+// CandidateResults are the votes for a candidate in their electorate.
+type CandidateResults struct {
+	ID                          string
+	FirstName                   string
+	LastName                    string
+	Party                       string
+	Elected                     bool
+	VotesInElectorate           VoteSummary
+	PreferenceVotesInElectorate VoteSummary
+}
+
+// ElectorateResults are the results of an electorate, as in the electorate
+// documents of the Firebase data.
+type ElectorateResults struct {
+	ElectorateID          ElectorateID
+	Name                  string
+	Enrolment             int
+	PollingPlacesExpected int
+	PollingPlacesReturned int
+	WinningParty          string
+	Candidates            map[string]*CandidateResults
+}
+
+// PartyResults are the votes and seats of a party in the nation.
+type PartyResults struct {
+	ID            string
+	Name          string
+	SeatsInNation int
+	VotesInNation VoteSummary
+}
+
+// NationResults are the nation-level results, as in the nation document of
+// the Firebase data.
+type NationResults struct {
+	Phase                    string
+	Updated                  string
+	Enrolment                int
+	Parties                  map[string]*PartyResults
+	WinningPartyByElectorate map[string]string
+}
+
+// ResultsUpdate is the body of POST /results. Either may be omitted, and
+// only the given electorates are updated.
+type ResultsUpdate struct {
+	Nation      *NationResults
+	Electorates map[ElectorateID]*ElectorateResults
+}
+
+// ResultsUpdateResponse is the response to POST /results.
+type ResultsUpdateResponse struct {
+	Events      int
+	LastEventID string
+}
+
+type resultsEvent struct {
+	seq  int64
+	kind string
+	key  string
+	data []byte
+}
+
+// resultsStore holds the latest results, and the events of their recent
+// changes. Streams wait on changed, which is closed and replaced whenever
+// there are new events.
+type resultsStore struct {
+	sync.Mutex
+	// epoch identifies the process in event IDs, so that IDs from before a
+	// restart aren't mistaken for recent ones.
+	epoch   string
+	seq     int64
+	latest  map[string]*resultsEvent
+	events  []*resultsEvent
+	changed chan struct{}
+}
+
+func newResultsStore() *resultsStore {
+	return &resultsStore{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		latest:  map[string]*resultsEvent{},
+		changed: make(chan struct{}),
+	}
+}
+
+var results = newResultsStore()
+
+// resultsServed is whether the API serves the results routes, see
+// ServeResults.
+var resultsServed bool
+
+// ServeResults adds /results and /results/stream to the API, and must be
+// called before NewAPIHandler. The results and their events are held in
+// memory, so they only work when the API runs in a single process, such as
+// runlocal: on App Engine an update would only reach the streams of the
+// instance which received it, and the go1 runtime buffers responses, so
+// events wouldn't be streamed as they happen.
+func ServeResults() {
+	resultsServed = true
+}
+
+func (s *resultsStore) eventID(seq int64) string {
+	return fmt.Sprintf("%v-%v", s.epoch, seq)
+}
+
+// add records an event for the results of key, unless they haven't changed.
+// It must be called with s locked.
+func (s *resultsStore) add(kind, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if previous, ok := s.latest[key]; ok && bytes.Equal(previous.data, data) {
+		return nil
+	}
+	s.seq++
+	e := &resultsEvent{seq: s.seq, kind: kind, key: key, data: data}
+	s.latest[key] = e
+	s.events = append(s.events, e)
+	if len(s.events) > MaxResultsEvents {
+		s.events = s.events[len(s.events)-MaxResultsEvents:]
+	}
+	return nil
+}
+
+// update records an event for the nation and each electorate of u whose
+// results changed, and returns the number of events and the ID of the last
+// event.
+func (s *resultsStore) update(u *ResultsUpdate) (*ResultsUpdateResponse, error) {
+	s.Lock()
+	defer s.Unlock()
+	seq := s.seq
+	if u.Nation != nil {
+		if err := s.add(ResultsEventNation, ResultsEventNation, u.Nation); err != nil {
+			return nil, err
+		}
+	}
+	var ids []string
+	for id := range u.Electorates {
+		ids = append(ids, string(id))
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		e := u.Electorates[ElectorateID(id)]
+		e.ElectorateID = ElectorateID(id)
+		if err := s.add(ResultsEventElectorate, ResultsEventElectorate+":"+id, e); err != nil {
+			return nil, err
+		}
+	}
+	if s.seq != seq {
+		close(s.changed)
+		s.changed = make(chan struct{})
+	}
+	return &ResultsUpdateResponse{Events: int(s.seq - seq), LastEventID: s.eventID(s.seq)}, nil
+}
+
+type bySeq []*resultsEvent
+
+func (e bySeq) Len() int           { return len(e) }
+func (e bySeq) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e bySeq) Less(i, j int) bool { return e[i].seq < e[j].seq }
+
+// since returns the events after the event with the given ID, or the latest
+// event of the nation and each electorate if lastEventID is empty or too
+// old, and a channel which is closed when there are more events.
+func (s *resultsStore) since(lastEventID string) ([]*resultsEvent, <-chan struct{}) {
+	s.Lock()
+	defer s.Unlock()
+	var seq int64 = -1
+	if i := strings.LastIndex(lastEventID, "-"); i >= 0 && lastEventID[:i] == s.epoch {
+		if n, err := strconv.ParseInt(lastEventID[i+1:], 10, 64); err == nil && n <= s.seq {
+			seq = n
+		}
+	}
+	var events []*resultsEvent
+	if seq < 0 || (len(s.events) > 0 && seq < s.events[0].seq-1) {
+		for _, e := range s.latest {
+			events = append(events, e)
+		}
+		sort.Sort(bySeq(events))
+	} else {
+		for _, e := range s.events {
+			if e.seq > seq {
+				events = append(events, e)
+			}
+		}
+	}
+	return events, s.changed
+}
+
+// checkResultsKey returns an error unless r has the key of ResultsKeyEnv.
+func checkResultsKey(r *http.Request) error {
+	key := os.Getenv(ResultsKeyEnv)
+	if key == "" {
+		return &APIError{
+			Status:  http.StatusUnauthorized,
+			Code:    CodeUnauthorized,
+			Message: fmt.Sprintf("Results can't be ingested, %v isn't set", ResultsKeyEnv),
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(APIKeyHeader)), []byte(key)) != 1 {
+		return &APIError{
+			Status:  http.StatusUnauthorized,
+			Code:    CodeUnauthorized,
+			Message: fmt.Sprintf("Expected the results key in the %v header", APIKeyHeader),
+			Field:   APIKeyHeader,
+		}
+	}
+	return nil
+}
+
+// decodeResultsUpdate decodes the body of POST /results, whose electorates
+// must exist.
+func decodeResultsUpdate(body io.Reader) (*ResultsUpdate, error) {
+	var u ResultsUpdate
+	decoder := json.NewDecoder(io.LimitReader(body, MaxResultsBodyBytes))
+	if err := decoder.Decode(&u); err != nil {
+		return nil, newBadRequestError(CodeInvalidBody, "", "", "Invalid results: %v", err)
+	}
+	for id, e := range u.Electorates {
+		if _, ok := electorates[id]; !ok {
+			return nil, newUnknownElectorateError(string(id))
+		}
+		if e == nil {
+			return nil, newBadRequestError(CodeInvalidBody, "", "", "No results for electorate %v", id)
+		}
+	}
+	return &u, nil
+}
+
+func resultsUpdateQuery(w http.ResponseWriter, r *http.Request) {
+	if err := checkResultsKey(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	u, err := decodeResultsUpdate(r.Body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	response, err := results.update(u)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Cache-control", "no-store")
+	w.Header().Set("Content-type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		writeError(w, err)
+	}
+}
+
+// resultsStreamQuery streams the changes to the results as Server-Sent
+// Events, starting with the current results unless the client resumes with
+// a Last-Event-ID header (or lastEventId parameter, for clients which can't
+// set headers) for which there are still events.
+func resultsStreamQuery(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, fmt.Errorf("Streaming isn't supported"))
+		return
+	}
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.FormValue("lastEventId")
+	}
+	w.Header().Set("Cache-control", "no-store")
+	w.Header().Set("Content-type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", ResultsStreamRetry/time.Millisecond)
+	flusher.Flush()
+	timeout := time.NewTimer(ResultsStreamDuration)
+	defer timeout.Stop()
+	heartbeat := time.NewTicker(ResultsStreamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		events, changed := results.since(lastEventID)
+		for _, e := range events {
+			lastEventID = results.eventID(e.seq)
+			if _, err := fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", lastEventID, e.kind, e.data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-timeout.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
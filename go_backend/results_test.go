@@ -0,0 +1,117 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResultsStoreSince(t *testing.T) {
+	s := newResultsStore()
+	banks := &ElectorateResults{Name: "Banks", PollingPlacesReturned: 1}
+	first, err := s.update(&ResultsUpdate{
+		Nation:      &NationResults{Phase: "Counting"},
+		Electorates: map[ElectorateID]*ElectorateResults{"banks": banks},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Events != 2 {
+		t.Errorf("Expected 2 events, got %v", first.Events)
+	}
+	// Unchanged results aren't events.
+	if response, _ := s.update(&ResultsUpdate{Nation: &NationResults{Phase: "Counting"}}); response.Events != 0 {
+		t.Errorf("Expected no events for unchanged results, got %v", response.Events)
+	}
+	banks = &ElectorateResults{Name: "Banks", PollingPlacesReturned: 2, WinningParty: "LP"}
+	if _, err := s.update(&ResultsUpdate{Electorates: map[ElectorateID]*ElectorateResults{"banks": banks}}); err != nil {
+		t.Fatal(err)
+	}
+	events, _ := s.since(first.LastEventID)
+	if len(events) != 1 || events[0].kind != ResultsEventElectorate || !strings.Contains(string(events[0].data), `"WinningParty":"LP"`) {
+		t.Errorf("Expected the change to Banks, got %v", events)
+	}
+	// Without a known Last-Event-ID, the latest results of each are sent.
+	for _, lastEventID := range []string{"", "0-1", "garbage"} {
+		events, _ = s.since(lastEventID)
+		if len(events) != 2 || events[0].kind != ResultsEventNation || events[1].seq != 3 {
+			t.Errorf("%q: expected the current results, got %v", lastEventID, events)
+		}
+	}
+}
+
+func TestResultsStream(t *testing.T) {
+	saved := results
+	defer func() { results = saved }()
+	results = newResultsStore()
+	first, _ := results.update(&ResultsUpdate{Nation: &NationResults{Phase: "Counting"}})
+	results.update(&ResultsUpdate{Nation: &NationResults{Phase: "PostElection"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/results/stream", nil).WithContext(ctx)
+	r.Header.Set("Last-Event-ID", first.LastEventID)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		resultsStreamQuery(w, r)
+		close(done)
+	}()
+	cancel()
+	<-done
+	body := w.Body.String()
+	if w.Header().Get("Content-type") != "text/event-stream" {
+		t.Errorf("Unexpected content type %v", w.Header().Get("Content-type"))
+	}
+	if strings.Contains(body, "Counting") || !strings.Contains(body, "event: nation\ndata: {\"Phase\":\"PostElection\"") {
+		t.Errorf("Expected only the second event, got %q", body)
+	}
+}
+
+func TestResultsUpdateRequiresKey(t *testing.T) {
+	defer os.Setenv(ResultsKeyEnv, os.Getenv(ResultsKeyEnv))
+	os.Setenv(ResultsKeyEnv, "secret")
+	for _, key := range []string{"", "wrong"} {
+		r := httptest.NewRequest("POST", "/results", strings.NewReader("{}"))
+		r.Header.Set(APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		resultsUpdateQuery(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%q: expected 401, got %v", key, w.Code)
+		}
+	}
+}
+
+func TestServeResults(t *testing.T) {
+	defer func() { resultsServed = false }()
+	serves := func() bool {
+		w := httptest.NewRecorder()
+		newAPIRouter(apiVersions, newRateLimiter(nil)).ServeHTTP(w, httptest.NewRequest("POST", "/v1/results", nil))
+		return w.Code != http.StatusNotFound
+	}
+	if serves() {
+		t.Error("Expected /results not to be served by default")
+	}
+	ServeResults()
+	if !serves() {
+		t.Error("Expected /results to be served after ServeResults")
+	}
+}
@@ -0,0 +1,763 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"fmt"
+	"strings"
+)
+
+// numberPattern matches the floats accepted by strconv.ParseFloat, other
+// than infinities and NaN.
+const numberPattern = `[-+]?([0-9]+\.?[0-9]*|\.[0-9]+)([eE][-+]?[0-9]+)?`
+
+// Parameters shared by several routes.
+var (
+	zoomParameter = &Parameter{
+		Name:        "zoom",
+		In:          "path",
+		Description: "Map zoom level, which determines how simplified the geometry is.",
+		Required:    true,
+		Schema:      &Schema{Type: "integer", Minimum: bound(0)},
+		InvalidCode: CodeInvalidZoom,
+	}
+	idsParameter = &Parameter{
+		Name:        "ids",
+		In:          "query",
+		Description: "Comma separated electorate IDs, or 'all'.",
+		Required:    true,
+		Schema:      &Schema{Type: "string"},
+		Example:     "lingiari,solomon",
+	}
+	locationParameter = &Parameter{
+		Name:     "location",
+		In:       "query",
+		Required: true,
+		Schema: &Schema{
+			Type:        "string",
+			Description: "'lat,lng'",
+			Pattern:     "^" + numberPattern + "," + numberPattern + "$",
+		},
+		Example:     "-12.4634,130.8456",
+		InvalidCode: CodeInvalidLocation,
+	}
+)
+
+func bboxParameter(required bool) *Parameter {
+	return &Parameter{
+		Name:     "bbox",
+		In:       "query",
+		Required: required,
+		Schema: &Schema{
+			Type:        "string",
+			Description: "'lat,lng,lat,lng'",
+			Pattern:     "^" + strings.Repeat(numberPattern+",", 3) + numberPattern + "$",
+		},
+		Example:     "-13.0,129.5,-11.5,132.0",
+		InvalidCode: CodeInvalidBbox,
+	}
+}
+
+// listParameter is a comma separated list of items.
+func listParameter(name, description string, items *Schema) *Parameter {
+	return &Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Style:       "form",
+		Explode:     new(bool),
+		Schema:      &Schema{Type: "array", Items: items},
+	}
+}
+
+func formatParameter(formats []string) *Parameter {
+	return &Parameter{
+		Name:        "format",
+		In:          "query",
+		Description: "Response format, takes precedence over the Accept header.",
+		Schema:      &Schema{Type: "string", Enum: formats, Default: formats[0]},
+		InvalidCode: CodeInvalidFormat,
+	}
+}
+
+func fieldsParameter() *Parameter {
+	return &Parameter{
+		Name: "fields",
+		In:   "query",
+		Description: fmt.Sprintf("Comma separated polling place fields, or the presets %v (id, name, "+
+			"electorate and wheelchair access), %v (and the address) and %v.", FieldsMinimal, FieldsAddress, FieldsFull),
+		Style:   "form",
+		Explode: new(bool),
+		Schema: &Schema{
+			Type:    "array",
+			Items:   &Schema{Type: "string", Enum: pollingPlaceFieldValues()},
+			Default: FieldsFull,
+		},
+	}
+}
+
+func tileParameter(name string, maximum float64) *Parameter {
+	return &Parameter{
+		Name:        name,
+		In:          "path",
+		Required:    true,
+		Schema:      &Schema{Type: "integer", Minimum: bound(0), Maximum: bound(maximum)},
+		InvalidCode: CodeInvalidTile,
+	}
+}
+
+// openAPISchemas are the schemas referred to by the operations.
+var openAPISchemas = map[string]*Schema{
+	"Error": {
+		Type:     "object",
+		Required: []string{"code", "message"},
+		Properties: map[string]*Schema{
+			"code": {
+				Type: "string",
+				Enum: []string{
+					CodeMissingParameter, CodeInvalidParameter, CodeInvalidZoom,
+					CodeInvalidBbox, CodeInvalidLocation, CodeUnknownElectorate,
+					CodeAllNotAllowedAtZoom, CodeLocationNotInElectorate,
+					CodeUnknownPostcode, CodeUnknownSuburb,
+					CodeInvalidTile, CodeQueryTooComplex, CodeInvalidBody, CodeInvalidFormat,
+					CodeNotAcceptable, CodeNotFound, CodeUnauthorized, CodeRateLimited, CodeNotReady,
+					CodeInternalError,
+				},
+			},
+			"message": {Type: "string"},
+			"field":   {Type: "string", Description: "The offending parameter."},
+			"value":   {Type: "string", Description: "The offending value."},
+		},
+	},
+	"FeatureCollection": {
+		Type:        "object",
+		Description: "A GeoJSON FeatureCollection, see RFC 7946.",
+		Required:    []string{"type", "features"},
+		Properties: map[string]*Schema{
+			"type":     {Type: "string", Enum: []string{"FeatureCollection"}},
+			"features": {Type: "array", Items: &Schema{Type: "object"}},
+		},
+	},
+	"AreaElectorates": {
+		Type:        "object",
+		Description: "Every candidate electorate, most likely first.",
+		Properties: map[string]*Schema{
+			"Postcode": {Type: "string"},
+			"Suburb":   {Type: "string"},
+			"Source": {
+				Type: "string",
+				Enum: []string{CoverageFromPostcodeBoundaries, CoverageFromPollingPlaces},
+			},
+			"Electorates": {
+				Type: "array",
+				Items: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"ElectorateID":  {Type: "string"},
+						"Name":          {Type: "string"},
+						"State":         {Type: "string"},
+						"Coverage":      {Type: "number", Description: "Estimated share of the area, or voters, in the electorate."},
+						"PollingPlaces": {Type: "integer"},
+					},
+				},
+			},
+		},
+	},
+	"GraphQLRequest": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"query":         {Type: "string"},
+			"variables":     {Type: "object"},
+			"operationName": {Type: "string"},
+		},
+	},
+	"GraphQLResponse": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"data":   {Type: "object"},
+			"errors": {Type: "array", Items: &Schema{Type: "object"}},
+		},
+	},
+	"ResultsUpdate": {
+		Type:        "object",
+		Description: "The nation and electorate documents of the Firebase data.",
+		Properties: map[string]*Schema{
+			"Nation":      {Type: "object"},
+			"Electorates": {Type: "object", Description: "Electorate results by electorate ID."},
+		},
+	},
+	"Location": {
+		Type: "object",
+		Properties: map[string]*Schema{
+			"Name":  {Type: "string"},
+			"ID":    {Type: "string"},
+			"State": {Type: "string"},
+			"GISID": {Type: "string", Description: "The polygon of the electorate containing the location."},
+			"BoundaryDistanceMetres": {
+				Type:        "number",
+				Description: "Distance to the nearest boundary of the electorate, which may be the coast.",
+			},
+			"Neighbour": {
+				Type: "object",
+				Description: fmt.Sprintf("The nearest other electorate, only if within %v metres.",
+					NeighbourDistanceMetres),
+				Properties: map[string]*Schema{
+					"Name":           {Type: "string"},
+					"ID":             {Type: "string"},
+					"State":          {Type: "string"},
+					"DistanceMetres": {Type: "number"},
+				},
+			},
+		},
+	},
+	"BatchLocation": {
+		Type:     "object",
+		Required: []string{"lat", "lng"},
+		Properties: map[string]*Schema{
+			"id":  {Type: "string"},
+			"lat": {Type: "number"},
+			"lng": {Type: "number"},
+		},
+	},
+	"BatchLocationResult": {
+		Type:        "object",
+		Description: "The electorate_id, name and state are omitted if the location isn't in an electorate.",
+		Properties: map[string]*Schema{
+			"id":            {Type: "string"},
+			"electorate_id": {Type: "string"},
+			"name":          {Type: "string"},
+			"state":         {Type: "string"},
+		},
+	},
+}
+
+func schemaRef(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func jsonContent(schema *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: schema}}
+}
+
+// electorateExportResponses describes the responses of
+// /electorates/{zoom}/export, in each of its formats.
+func electorateExportResponses() map[string]*Response {
+	content := map[string]*MediaType{}
+	var descriptions []string
+	for _, format := range electorateExportFormats {
+		exporter := electorateExporters[format]
+		content[exporter.ContentType] = &MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		descriptions = append(descriptions, fmt.Sprintf("%v for %v", exporter.Description, format))
+	}
+	return withErrors(map[string]*Response{
+		"200": {
+			Description: "The electorates: " + strings.Join(descriptions, "; ") + ".",
+			Content:     content,
+		},
+	})
+}
+
+// pollingPlaceSelectionParameters are the parameters of /polling_places and
+// its exports which select polling places.
+func pollingPlaceSelectionParameters() []*Parameter {
+	return []*Parameter{
+		{
+			Name:        "ids",
+			In:          "query",
+			Description: "Comma separated electorate IDs.",
+			Schema:      &Schema{Type: "string"},
+			Example:     "denison,lyons",
+		},
+		listParameter("state", "States, by abbreviation.",
+			&Schema{Type: "string", Enum: pollingPlaceStates}),
+		bboxParameter(false),
+		listParameter("wheelchair_access", "Levels of wheelchair access.",
+			&Schema{Type: "string", Enum: pollingPlaceWheelchairAccess}),
+		listParameter("status", "Current polling places, or those by appointment.",
+			&Schema{Type: "string", Enum: pollingPlaceStatuses}),
+		listParameter("postcode", "Postcodes.", &Schema{Type: "integer", Minimum: bound(0)}),
+		{
+			Name:        "min_ordinary_vote_estimate",
+			In:          "query",
+			Description: "Minimum estimated number of ordinary votes.",
+			Schema:      &Schema{Type: "integer", Minimum: bound(0)},
+		},
+	}
+}
+
+// pollingPlaceExportRoute is the route of /polling_places.{ext}.
+func pollingPlaceExportRoute(ext string) *apiRoute {
+	exporter := pollingPlaceExporters[ext]
+	return &apiRoute{
+		Path:    "/polling_places." + ext,
+		Handler: pollingPlacesExportQuery(ext),
+		Operation: &Operation{
+			OperationID: "exportPollingPlaces" + strings.ToUpper(ext),
+			Summary:     fmt.Sprintf("Polling places of electorates, states or a bbox as %v", strings.ToUpper(ext)),
+			Description: "The polling places of /polling_places, with their addresses and accessibility, " +
+				"as a download. One of ids, state or bbox is required.",
+			Parameters: pollingPlaceSelectionParameters(),
+			Responses: withErrors(map[string]*Response{
+				"200": {
+					Description: exporter.Description + ".",
+					Content: map[string]*MediaType{
+						// Without the charset parameter.
+						strings.SplitN(exporter.ContentType, ";", 2)[0]: {Schema: &Schema{Type: "string"}},
+					},
+				},
+			}),
+		},
+	}
+}
+
+// withErrors adds the error responses common to every operation.
+func withErrors(responses map[string]*Response) map[string]*Response {
+	responses["400"] = &Response{Description: "Invalid parameters.", Content: jsonContent(schemaRef("Error"))}
+	responses["429"] = &Response{
+		Description: "Too many requests, retry after the number of seconds in the Retry-After header.",
+		Content:     jsonContent(schemaRef("Error")),
+	}
+	responses["default"] = &Response{Description: "Error.", Content: jsonContent(schemaRef("Error"))}
+	return responses
+}
+
+// featureCollectionResponses describes the responses of a handler which
+// writes a feature collection in one of formats.
+func featureCollectionResponses(description string, formats []string) map[string]*Response {
+	content := map[string]*MediaType{}
+	for _, f := range formats {
+		encoder := featureCollectionEncoders[f]
+		switch f {
+		case FormatGeoJSON:
+			content[encoder.ContentType] = &MediaType{Schema: schemaRef("FeatureCollection")}
+		case FormatGeobuf:
+			content[encoder.ContentType] = &MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		default:
+			content[encoder.ContentType] = &MediaType{Schema: &Schema{Type: "object", Description: f}}
+		}
+	}
+	return withErrors(map[string]*Response{
+		"200": {
+			Description: fmt.Sprintf("%v, in one of %v.", description, strings.Join(formats, ", ")),
+			Content:     content,
+		},
+	})
+}
+
+// apiRoutes returns every route of version 1 of the API, in the order
+// they're matched.
+func apiRoutes() []*apiRoute {
+	routes := []*apiRoute{
+		{
+			Path:      "/electorates/{zoom}",
+			Handler:   electoratesQuery,
+			RateLimit: electoratesRateLimit,
+			Operation: &Operation{
+				OperationID: "getElectorates",
+				Summary:     "Geometry of electorates",
+				Description: fmt.Sprintf("ids=all is only allowed up to zoom %v.", MaxZoomForAllElectorates),
+				Parameters:  []*Parameter{zoomParameter, idsParameter, formatParameter(electorateFormats)},
+				Responses:   featureCollectionResponses("The electorates", electorateFormats),
+			},
+		},
+		{
+			Path:      "/electorates/{zoom}/export",
+			Handler:   electoratesExportQuery,
+			RateLimit: electoratesRateLimit,
+			Operation: &Operation{
+				OperationID: "exportElectorates",
+				Summary:     "Geometry of electorates as a GIS download",
+				Description: fmt.Sprintf("The simplified geometry served at the zoom level, with the id, name, state "+
+					"and area_sqkm of each electorate. ids=all is only allowed up to zoom %v.", MaxZoomForAllElectorates),
+				Parameters: []*Parameter{
+					zoomParameter,
+					idsParameter,
+					{
+						Name:        "format",
+						In:          "query",
+						Description: "kml, a zipped ESRI Shapefile or a GeoPackage.",
+						Schema:      &Schema{Type: "string", Enum: electorateExportFormats, Default: electorateExportFormats[0]},
+						InvalidCode: CodeInvalidFormat,
+					},
+				},
+				Responses: electorateExportResponses(),
+			},
+		},
+		{
+			Path:      "/location",
+			Handler:   locationQuery,
+			RateLimit: locationRateLimit,
+			Operation: &Operation{
+				OperationID: "getLocation",
+				Summary:     "The electorate containing a location",
+				Parameters:  []*Parameter{locationParameter},
+				Responses: withErrors(map[string]*Response{
+					"200": {
+						Description: "The electorate, and how close the location is to its boundary.",
+						Content:     jsonContent(schemaRef("Location")),
+					},
+					"404": {
+						Description: "The location isn't in any electorate.",
+						Content:     jsonContent(schemaRef("Error")),
+					},
+				}),
+			},
+		},
+		{
+			Path:      "/locations",
+			Method:    "POST",
+			Handler:   locationsQuery,
+			RateLimit: locationsRateLimit,
+			Operation: &Operation{
+				OperationID: "postLocations",
+				Summary:     "The electorates containing a batch of locations",
+				RequestBody: &RequestBody{
+					Description: fmt.Sprintf("Up to %v locations, and at most %v bytes.", MaxBatchLocations, MaxBatchLocationsBodyBytes),
+					Required:    true,
+					Content: map[string]*MediaType{
+						"application/json": {Schema: &Schema{Type: "array", Items: schemaRef("BatchLocation")}},
+						NDJSONContentType:  {Schema: schemaRef("BatchLocation")},
+					},
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {
+						Description: "The electorate of each location, in the request's content type.",
+						Content: map[string]*MediaType{
+							"application/json": {Schema: &Schema{Type: "array", Items: schemaRef("BatchLocationResult")}},
+							NDJSONContentType:  {Schema: schemaRef("BatchLocationResult")},
+						},
+					},
+				}),
+			},
+		},
+		{
+			Path:    "/viewport/{zoom}",
+			Handler: viewportQuery,
+			Operation: &Operation{
+				OperationID: "getViewport",
+				Summary:     "Electorates in a viewport",
+				Parameters: []*Parameter{
+					zoomParameter,
+					bboxParameter(true),
+					{
+						Name:        "include",
+						In:          "query",
+						Description: "Additional features to include.",
+						Style:       "form",
+						Explode:     new(bool),
+						Schema:      &Schema{Type: "array", Items: &Schema{Type: "string", Enum: []string{IncludePollingPlaces}}},
+					},
+					fieldsParameter(),
+					formatParameter(viewportFormats),
+				},
+				Responses: featureCollectionResponses("Electorates, or labels, in the viewport", viewportFormats),
+			},
+		},
+		{
+			Path:      "/zoom_buckets",
+			Handler:   zoomBucketsQuery,
+			RateLimit: staticRateLimit,
+			Operation: &Operation{
+				OperationID: "getZoomBuckets",
+				Summary:     "Zoom levels at which geometry is simplified",
+				Responses: withErrors(map[string]*Response{
+					"200": {
+						Description: "The zoom levels.",
+						Content:     jsonContent(&Schema{Type: "array", Items: &Schema{Type: "integer"}}),
+					},
+				}),
+			},
+		},
+		{
+			Path:    "/polling_places",
+			Handler: pollingPlacesQuery,
+			Operation: &Operation{
+				OperationID: "getPollingPlaces",
+				Summary:     "Polling places of electorates, states or a bbox",
+				Description: "One of ids, state or bbox is required. Polling place groups are only " +
+					"included for electorates without any filter.",
+				Parameters: append(pollingPlaceSelectionParameters(),
+					fieldsParameter(),
+					formatParameter(pollingPlaceFormats),
+				),
+				Responses: featureCollectionResponses("The polling places", pollingPlaceFormats),
+			},
+		},
+		pollingPlaceExportRoute(ExportCSV),
+		pollingPlaceExportRoute(ExportKML),
+		pollingPlaceExportRoute(ExportGPX),
+		{
+			Path:      "/nearest_polling_places",
+			Handler:   nearestPollingPlacesQuery,
+			RateLimit: locationRateLimit,
+			Operation: &Operation{
+				OperationID: "getNearestPollingPlaces",
+				Summary:     "Polling places nearest to a location",
+				Parameters: []*Parameter{
+					locationParameter,
+					{
+						Name:   "k",
+						In:     "query",
+						Schema: &Schema{Type: "integer", Minimum: bound(1), Maximum: bound(MaxNearestPollingPlaces), Default: DefaultNearestPollingPlaces},
+					},
+					{
+						Name:        "same_electorate",
+						In:          "query",
+						Description: "Only return polling places in the location's electorate.",
+						Schema:      &Schema{Type: "boolean", Default: false},
+					},
+					fieldsParameter(),
+					formatParameter(pollingPlaceFormats),
+				},
+				Responses: featureCollectionResponses("The polling places, nearest first", pollingPlaceFormats),
+			},
+		},
+		{
+			Path:      "/postcode/{pc}",
+			Handler:   postcodeQuery,
+			RateLimit: locationRateLimit,
+			Operation: &Operation{
+				OperationID: "getPostcode",
+				Summary:     "Electorates of a postcode",
+				Description: "Coverage is estimated from the area of the postcode in each electorate if " +
+					"ABS postcode boundaries are loaded, and otherwise from its polling places.",
+				Parameters: []*Parameter{
+					{
+						Name:     "pc",
+						In:       "path",
+						Required: true,
+						Schema:   &Schema{Type: "string", Description: "a 3 or 4 digit postcode", Pattern: "^[0-9]{3,4}$"},
+						Example:  "2220",
+					},
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {Description: "The candidate electorates.", Content: jsonContent(schemaRef("AreaElectorates"))},
+					"404": {Description: "Unknown postcode.", Content: jsonContent(schemaRef("Error"))},
+				}),
+			},
+		},
+		{
+			Path:      "/suburb/{name}",
+			Handler:   suburbQuery,
+			RateLimit: locationRateLimit,
+			Operation: &Operation{
+				OperationID: "getSuburb",
+				Summary:     "Electorates of a suburb",
+				Description: "Coverage is estimated from the polling places of the suburb.",
+				Parameters: []*Parameter{
+					{
+						Name:     "name",
+						In:       "path",
+						Required: true,
+						Schema:   &Schema{Type: "string"},
+						Example:  "Hurstville",
+					},
+					{
+						Name:        "state",
+						In:          "query",
+						Description: "As many suburb names are used in several states.",
+						Schema:      &Schema{Type: "string", Enum: pollingPlaceStates},
+					},
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {Description: "The candidate electorates.", Content: jsonContent(schemaRef("AreaElectorates"))},
+					"404": {Description: "Unknown suburb.", Content: jsonContent(schemaRef("Error"))},
+				}),
+			},
+		},
+		{
+			Path:      "/search",
+			Handler:   searchQuery,
+			RateLimit: searchRateLimit,
+			Operation: &Operation{
+				OperationID: "search",
+				Summary:     "Search polling places and electorates",
+				Description: "Matches names and addresses of polling places, and names of electorates, " +
+					"by word prefixes and with typos.",
+				Parameters: []*Parameter{
+					{
+						Name:        "q",
+						In:          "query",
+						Description: fmt.Sprintf("At most %v characters and %v words.", MaxSearchQueryLength, MaxSearchTerms),
+						Required:    true,
+						Schema:      &Schema{Type: "string"},
+						Example:     "hurstville public school",
+					},
+					{
+						Name:   "limit",
+						In:     "query",
+						Schema: &Schema{Type: "integer", Minimum: bound(1), Maximum: bound(MaxSearchResults), Default: DefaultSearchResults},
+					},
+					fieldsParameter(),
+					formatParameter(pollingPlaceFormats),
+				},
+				Responses: featureCollectionResponses("The polling places and electorates, best match first, "+
+					"each with a score property", pollingPlaceFormats),
+			},
+		},
+		{
+			Path:      "/graphql",
+			Handler:   graphQLQuery,
+			RateLimit: graphQLRateLimit,
+			Operation: &Operation{
+				OperationID: "getGraphQL",
+				Summary:     "GraphQL query of electorates and polling places",
+				Description: "Errors of the query are in the errors of the GraphQL response, with their code in extensions.",
+				Parameters: []*Parameter{
+					{
+						Name:     "query",
+						In:       "query",
+						Required: true,
+						Schema:   &Schema{Type: "string"},
+						Example:  `{electorate(id: "banks") {name pollingPlaces(wheelchairAccess: [Full]) {prettyPrintName}}}`,
+					},
+					{
+						Name:        "variables",
+						In:          "query",
+						Description: "A JSON object.",
+						Schema:      &Schema{Type: "string"},
+					},
+					{
+						Name:   "operationName",
+						In:     "query",
+						Schema: &Schema{Type: "string"},
+					},
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {Description: "The GraphQL response.", Content: jsonContent(schemaRef("GraphQLResponse"))},
+				}),
+			},
+		},
+		{
+			Path:      "/graphql",
+			Method:    "POST",
+			Handler:   graphQLQuery,
+			RateLimit: graphQLRateLimit,
+			Operation: &Operation{
+				OperationID: "postGraphQL",
+				Summary:     "GraphQL query of electorates and polling places",
+				RequestBody: &RequestBody{
+					Description: fmt.Sprintf("At most %v bytes.", MaxGraphQLBodyBytes),
+					Required:    true,
+					Content: map[string]*MediaType{
+						"application/json":    {Schema: schemaRef("GraphQLRequest")},
+						"application/graphql": {Schema: &Schema{Type: "string"}},
+					},
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {Description: "The GraphQL response.", Content: jsonContent(schemaRef("GraphQLResponse"))},
+				}),
+			},
+		},
+		{
+			Path:      "/tiles/{z}/{x}/{y}.mvt",
+			Pattern:   "/tiles/{z:[0-9]+}/{x:[0-9]+}/{y:[0-9]+}.mvt",
+			Handler:   tileQuery,
+			RateLimit: tileRateLimit,
+			Operation: &Operation{
+				OperationID: "getTile",
+				Summary:     "Mapbox Vector Tile of electorates",
+				Parameters: []*Parameter{
+					tileParameter("z", MaxTileZoom),
+					tileParameter("x", 1<<MaxTileZoom-1),
+					tileParameter("y", 1<<MaxTileZoom-1),
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {
+						Description: "The tile.",
+						Content: map[string]*MediaType{
+							VectorTileContentType: {Schema: &Schema{Type: "string", Format: "binary"}},
+						},
+					},
+				}),
+			},
+		},
+	}
+	if resultsServed {
+		routes = append(routes, resultsRoutes()...)
+	}
+	return routes
+}
+
+// resultsRoutes returns the routes of the results, which are only served
+// after ServeResults.
+func resultsRoutes() []*apiRoute {
+	return []*apiRoute{
+		{
+			Path:    "/results",
+			Method:  "POST",
+			Handler: resultsUpdateQuery,
+			Operation: &Operation{
+				OperationID: "postResults",
+				Summary:     "Ingest results",
+				Description: fmt.Sprintf("Requires the key in %v in the %v header. An event is streamed "+
+					"for the nation and each electorate whose results changed.", ResultsKeyEnv, APIKeyHeader),
+				RequestBody: &RequestBody{
+					Description: fmt.Sprintf("At most %v bytes.", MaxResultsBodyBytes),
+					Required:    true,
+					Content:     jsonContent(schemaRef("ResultsUpdate")),
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {
+						Description: "The number of events and the ID of the last one.",
+						Content: jsonContent(&Schema{
+							Type: "object",
+							Properties: map[string]*Schema{
+								"Events":      {Type: "integer"},
+								"LastEventID": {Type: "string"},
+							},
+						}),
+					},
+					"401": {Description: "The key is missing or wrong.", Content: jsonContent(schemaRef("Error"))},
+				}),
+			},
+		},
+		{
+			Path:    "/results/stream",
+			Handler: resultsStreamQuery,
+			Operation: &Operation{
+				OperationID: "streamResults",
+				Summary:     "Server-Sent Events of results changes",
+				Description: fmt.Sprintf("Events are named %v and %v, with the changed results as data. "+
+					"The current results are sent first, unless the client resumes with Last-Event-ID. "+
+					"The stream is closed after %v, and clients reconnect.",
+					ResultsEventNation, ResultsEventElectorate, ResultsStreamDuration),
+				Parameters: []*Parameter{
+					{
+						Name:        "Last-Event-ID",
+						In:          "header",
+						Description: "The ID of the last event received.",
+						Schema:      &Schema{Type: "string"},
+					},
+					{
+						Name:        "lastEventId",
+						In:          "query",
+						Description: "Last-Event-ID, for clients which can't set headers.",
+						Schema:      &Schema{Type: "string"},
+					},
+				},
+				Responses: withErrors(map[string]*Response{
+					"200": {
+						Description: "The event stream.",
+						Content:     map[string]*MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}},
+					},
+				}),
+			},
+		},
+	}
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// TypeElectorate is the type of electorate search results.
+const TypeElectorate = "electorate"
+
+// DefaultSearchResults is the number of results returned by searchQuery
+// when limit isn't specified.
+const DefaultSearchResults = 10
+
+// MaxSearchResults is the largest limit accepted by searchQuery.
+const MaxSearchResults = 50
+
+// MaxSearchQueryLength and MaxSearchTerms bound the q accepted by
+// searchQuery, as each term is compared with every indexed term for typos.
+const (
+	MaxSearchQueryLength = 100
+	MaxSearchTerms       = 8
+)
+
+// Scores of a query term matching an indexed term, relative to the weight of
+// the field the indexed term is in.
+const (
+	exactMatchScore  = 1.0
+	prefixMatchScore = 0.7
+	typoMatchScore   = 0.5
+)
+
+// searchDocument is a polling place or an electorate.
+type searchDocument struct {
+	// pollingPlace is the index of the polling place, or -1 for electorates.
+	pollingPlace int
+	electorate   *Electorate
+	name         string
+}
+
+type searchPosting struct {
+	doc    int
+	weight float64
+}
+
+// searchIndex is an inverted index from terms to the documents they're in.
+type searchIndex struct {
+	docs     []searchDocument
+	postings map[string][]searchPosting
+	// terms are the keys of postings, sorted for prefix searches.
+	terms []string
+}
+
+// textIndex is built by initSpatial.
+var textIndex *searchIndex
+
+// tokenize splits s into lower case terms. Apostrophes are dropped rather
+// than split on, so that "St Raphael's" matches "raphaels".
+func tokenize(s string) []string {
+	s = strings.Replace(strings.ToLower(s), "'", "", -1)
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (idx *searchIndex) add(doc searchDocument, fields map[string]float64) {
+	docIndex := len(idx.docs)
+	idx.docs = append(idx.docs, doc)
+	weights := map[string]float64{}
+	for text, weight := range fields {
+		for _, term := range tokenize(text) {
+			if weight > weights[term] {
+				weights[term] = weight
+			}
+		}
+	}
+	for term, weight := range weights {
+		idx.postings[term] = append(idx.postings[term], searchPosting{docIndex, weight})
+	}
+}
+
+// newSearchIndex indexes the names and addresses of polling places, and the
+// names of electorates. Names weigh more than addresses.
+func newSearchIndex() *searchIndex {
+	idx := &searchIndex{postings: map[string][]searchPosting{}}
+	for i, p := range pollingPlaces {
+		idx.add(searchDocument{pollingPlace: i, name: p.PrettyPrintName}, map[string]float64{
+			p.PrettyPrintName: 3,
+			p.PremisesName:    2,
+			p.AddressSuburb:   2,
+			p.Address1:        1,
+		})
+	}
+	for _, e := range electorates {
+		idx.add(searchDocument{pollingPlace: -1, electorate: e, name: e.name}, map[string]float64{
+			e.name: 4,
+		})
+	}
+	for term := range idx.postings {
+		idx.terms = append(idx.terms, term)
+	}
+	sort.Strings(idx.terms)
+	return idx
+}
+
+func initSearchIndex() {
+	textIndex = newSearchIndex()
+}
+
+// maxTypos is the edit distance tolerated for a query term; short terms
+// must be spelt correctly.
+func maxTypos(term string) int {
+	switch n := len(term); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// editDistance returns the optimal string alignment distance between a and
+// b, or max+1 if it's greater than max.
+func editDistance(a, b string, max int) int {
+	if d := len(a) - len(b); d > max || -d > max {
+		return max + 1
+	}
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+			rowMin = minInt(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	if prev[len(b)] > max {
+		return max + 1
+	}
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// matchTerm returns the score of each document for a query term: the best of
+// exact, prefix and typo tolerant matches of the indexed terms.
+func (idx *searchIndex) matchTerm(q string) map[int]float64 {
+	scores := map[int]float64{}
+	addTerm := func(term string, score float64) {
+		for _, p := range idx.postings[term] {
+			if s := score * p.weight; s > scores[p.doc] {
+				scores[p.doc] = s
+			}
+		}
+	}
+	addTerm(q, exactMatchScore)
+	// Terms with q as a prefix are contiguous in the sorted terms.
+	for i := sort.SearchStrings(idx.terms, q); i < len(idx.terms) && strings.HasPrefix(idx.terms[i], q); i++ {
+		if idx.terms[i] != q {
+			addTerm(idx.terms[i], prefixMatchScore)
+		}
+	}
+	if max := maxTypos(q); max > 0 {
+		for _, term := range idx.terms {
+			if d := editDistance(q, term, max); d > 0 && d <= max {
+				addTerm(term, typoMatchScore/float64(d))
+			}
+		}
+	}
+	return scores
+}
+
+type searchResult struct {
+	doc   int
+	score float64
+}
+
+type byScore struct {
+	results []searchResult
+	docs    []searchDocument
+}
+
+func (s byScore) Len() int      { return len(s.results) }
+func (s byScore) Swap(i, j int) { s.results[i], s.results[j] = s.results[j], s.results[i] }
+func (s byScore) Less(i, j int) bool {
+	if s.results[i].score != s.results[j].score {
+		return s.results[i].score > s.results[j].score
+	}
+	return s.docs[s.results[i].doc].name < s.docs[s.results[j].doc].name
+}
+
+// search returns the documents matching every term of q, best first.
+func (idx *searchIndex) search(q string, limit int) []searchResult {
+	var scores map[int]float64
+	for _, term := range tokenize(q) {
+		termScores := idx.matchTerm(term)
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for doc, score := range scores {
+			if termScore, ok := termScores[doc]; ok {
+				scores[doc] = score + termScore
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+	results := make([]searchResult, 0, len(scores))
+	for doc, score := range scores {
+		results = append(results, searchResult{doc, score})
+	}
+	sort.Sort(byScore{results, idx.docs})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// electorateCentroid returns the centroid of the largest polygon of e.
+func electorateCentroid(e *Electorate) []float64 {
+	var largest *ElectoratePolygon
+	for _, ep := range e.polygons[highestZoomLevel] {
+		if largest == nil || ep.area > largest.area {
+			largest = ep
+		}
+	}
+	if largest == nil {
+		return []float64{(e.bbox.MinX + e.bbox.MaxX) / 2, (e.bbox.MinY + e.bbox.MaxY) / 2}
+	}
+	return []float64{float64(largest.centLong), float64(largest.centLat)}
+}
+
+// querySearch returns a point-feature-collection of the polling places and
+// electorates best matching q, with the given fields of polling places and
+// a score property.
+func querySearch(q string, limit int, fields pollingPlaceFields) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for _, result := range textIndex.search(q, limit) {
+		doc := textIndex.docs[result.doc]
+		var feature *geojson.Feature
+		if doc.electorate != nil {
+			feature = geojson.NewPointFeature(electorateCentroid(doc.electorate))
+			feature.ID = string(doc.electorate.id)
+			feature.Properties["type"] = TypeElectorate
+			feature.Properties["Name"] = doc.electorate.name
+			feature.Properties["State"] = doc.electorate.state
+		} else {
+			feature = pollingPlaces[doc.pollingPlace].toFeature(fields)
+			feature.Properties["minZoom"] = pollingPlaceMinZoom[doc.pollingPlace]
+		}
+		feature.Properties["score"] = result.score
+		fc.AddFeature(feature)
+	}
+	return fc
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		max      int
+		expected int
+	}{
+		{"oatley", "oatley", 1, 0},
+		{"oatly", "oatley", 1, 1},
+		{"oatlye", "oatley", 1, 1},
+		{"hurstvile", "hurstville", 2, 1},
+		{"hurtsvile", "hurstville", 2, 2},
+		{"kogarah", "oatley", 2, 3},
+	}
+	for _, tt := range tests {
+		if d := editDistance(tt.a, tt.b, tt.max); d != tt.expected {
+			t.Errorf("editDistance(%v, %v, %v): expected %v, got %v", tt.a, tt.b, tt.max, tt.expected, d)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	idx := newSearchIndex()
+	tests := []struct {
+		q        string
+		expected string
+	}{
+		{"Hurstville Public School", "Hurstville"},
+		{"hurstville public", "Hurstville"},
+		// Prefix
+		{"hurstv pub", "Hurstville"},
+		// Typo
+		{"hurstvile public school", "Hurstville"},
+		{"Oatley", "Oatley"},
+	}
+	for _, tt := range tests {
+		results := idx.search(tt.q, 5)
+		if len(results) == 0 {
+			t.Errorf("%v: no results", tt.q)
+			continue
+		}
+		p := pollingPlaces[idx.docs[results[0].doc].pollingPlace]
+		if !strings.Contains(p.PrettyPrintName, tt.expected) {
+			t.Errorf("%v: expected %v first, got %v (%v)", tt.q, tt.expected, p.PrettyPrintName, p.PremisesName)
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].score > results[i-1].score {
+				t.Errorf("%v: results aren't ranked by score", tt.q)
+			}
+		}
+	}
+	if results := idx.search("xyzzy", 5); len(results) != 0 {
+		t.Errorf("Expected no results, got %v", len(results))
+	}
+}
+
+func TestSearchQueryTooLong(t *testing.T) {
+	for _, q := range []string{
+		strings.Repeat("a", MaxSearchQueryLength+1),
+		strings.Repeat("hurst ", MaxSearchTerms+1),
+	} {
+		w := httptest.NewRecorder()
+		searchQuery(w, httptest.NewRequest("GET", "/search?q="+url.QueryEscape(q), nil))
+		var response APIError
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != 400 || response.Code != CodeInvalidParameter || response.Field != "q" {
+			t.Errorf("%v: expected a 400 %v error for q, got %v %+v", q, CodeInvalidParameter, w.Code, response)
+		}
+	}
+}
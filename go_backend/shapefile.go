@@ -0,0 +1,186 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+
+	shp "github.com/jonas-p/go-shp"
+)
+
+// wgs84PRJ is the .prj of shapefiles in WGS 84 longitude and latitude, the
+// coordinate system the dataset is reprojected to.
+const wgs84PRJ = `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],` +
+	`PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+
+// dbfField is a column of a dBASE table.
+type dbfField struct {
+	Name     string
+	Type     byte
+	Length   int
+	Decimals int
+}
+
+// writeShapefileHeader writes the header shared by .shp and .shx files, of a
+// file of length bytes.
+func writeShapefileHeader(w io.Writer, length int, shapeType shp.ShapeType, box shp.Box) {
+	binary.Write(w, binary.BigEndian, [7]int32{9994, 0, 0, 0, 0, 0, int32(length / 2)})
+	binary.Write(w, binary.LittleEndian, int32(1000))
+	binary.Write(w, binary.LittleEndian, int32(shapeType))
+	binary.Write(w, binary.LittleEndian, [8]float64{box.MinX, box.MinY, box.MaxX, box.MaxY})
+}
+
+// writeDBF writes a dBASE III table of records, the values of fields as text.
+func writeDBF(w io.Writer, fields []dbfField, records [][]string) {
+	recordLength := 1
+	for _, f := range fields {
+		recordLength += f.Length
+	}
+	// The last update is the date of the election, so that exports don't
+	// depend on when they're made.
+	w.Write([]byte{0x03, 116, 7, 2})
+	binary.Write(w, binary.LittleEndian, uint32(len(records)))
+	binary.Write(w, binary.LittleEndian, uint16(32+32*len(fields)+1))
+	binary.Write(w, binary.LittleEndian, uint16(recordLength))
+	w.Write(make([]byte, 20))
+	for _, f := range fields {
+		var descriptor [32]byte
+		copy(descriptor[:10], f.Name)
+		descriptor[11] = f.Type
+		descriptor[16] = byte(f.Length)
+		descriptor[17] = byte(f.Decimals)
+		w.Write(descriptor[:])
+	}
+	w.Write([]byte{0x0d})
+	for _, record := range records {
+		w.Write([]byte{' '})
+		for i, f := range fields {
+			value := record[i]
+			if len(value) > f.Length {
+				value = value[:f.Length]
+			}
+			padding := strings.Repeat(" ", f.Length-len(value))
+			if f.Type == 'N' {
+				// Numbers are right aligned.
+				io.WriteString(w, padding+value)
+			} else {
+				io.WriteString(w, value+padding)
+			}
+		}
+	}
+	w.Write([]byte{0x1a})
+}
+
+// exportElectoratesShapefile writes a zip of a polygon shapefile called name,
+// with a record per electorate of all of its polygons.
+func exportElectoratesShapefile(w io.Writer, name string, zoom ZoomLevel, ids []ElectorateID) error {
+	var shpRecords, shxRecords bytes.Buffer
+	var box shp.Box
+	records := make([][]string, len(ids))
+	fields := []dbfField{
+		{Name: "ID", Type: 'C', Length: 1},
+		{Name: "NAME", Type: 'C', Length: 1},
+		{Name: "STATE", Type: 'C', Length: 1},
+		{Name: "AREA_SQKM", Type: 'N', Length: 1},
+	}
+	for i, id := range ids {
+		e := electorates[id]
+		eps := e.polygons[zoom]
+		eBox := polygonsBox(eps)
+		if i == 0 {
+			box = eBox
+		} else {
+			box.Extend(eBox)
+		}
+		var parts []int32
+		var points []shp.Point
+		for _, ep := range eps {
+			for _, ring := range polygonRings(ep) {
+				parts = append(parts, int32(len(points)))
+				points = append(points, ring...)
+			}
+		}
+		// Record offsets and content lengths are in 16 bit words.
+		contentLength := 44 + 4*len(parts) + 16*len(points)
+		binary.Write(&shxRecords, binary.BigEndian, [2]int32{int32(100+shpRecords.Len()) / 2, int32(contentLength / 2)})
+		binary.Write(&shpRecords, binary.BigEndian, [2]int32{int32(i + 1), int32(contentLength / 2)})
+		binary.Write(&shpRecords, binary.LittleEndian, int32(shp.POLYGON))
+		binary.Write(&shpRecords, binary.LittleEndian, [4]float64{eBox.MinX, eBox.MinY, eBox.MaxX, eBox.MaxY})
+		binary.Write(&shpRecords, binary.LittleEndian, [2]int32{int32(len(parts)), int32(len(points))})
+		binary.Write(&shpRecords, binary.LittleEndian, parts)
+		binary.Write(&shpRecords, binary.LittleEndian, points)
+
+		records[i] = []string{string(e.id), e.name, e.state, strconv.FormatFloat(float64(e.areaSqkm), 'f', -1, 32)}
+		for j, value := range records[i] {
+			if len(value) > fields[j].Length {
+				fields[j].Length = len(value)
+			}
+		}
+	}
+	// Areas are written with as many decimals as the most precise one.
+	area := &fields[3]
+	for _, record := range records {
+		if i := strings.Index(record[3], "."); i >= 0 && len(record[3])-i-1 > area.Decimals {
+			area.Decimals = len(record[3]) - i - 1
+		}
+	}
+	for i, record := range records {
+		record[3] = strconv.FormatFloat(float64(electorates[ids[i]].areaSqkm), 'f', area.Decimals, 32)
+		if len(record[3]) > area.Length {
+			area.Length = len(record[3])
+		}
+	}
+	for i := range fields {
+		if fields[i].Length > 254 {
+			fields[i].Length = 254
+		}
+	}
+
+	var shpFile, shxFile, dbfFile bytes.Buffer
+	writeShapefileHeader(&shpFile, 100+shpRecords.Len(), shp.POLYGON, box)
+	shpFile.Write(shpRecords.Bytes())
+	writeShapefileHeader(&shxFile, 100+shxRecords.Len(), shp.POLYGON, box)
+	shxFile.Write(shxRecords.Bytes())
+	writeDBF(&dbfFile, fields, records)
+
+	zw := zip.NewWriter(w)
+	for _, file := range []struct {
+		ext     string
+		content []byte
+	}{
+		{"shp", shpFile.Bytes()},
+		{"shx", shxFile.Bytes()},
+		{"dbf", dbfFile.Bytes()},
+		{"prj", []byte(wgs84PRJ)},
+		{"cpg", []byte("UTF-8")},
+	} {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name + "." + file.ext, Method: zip.Deflate})
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
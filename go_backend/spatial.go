@@ -105,7 +105,7 @@ func ShpPolygonToGeojsonFeature(eps []*ElectoratePolygon) *geojson.Feature {
 func electorateToGeoJsonFeature(id ElectorateID, z ZoomLevel) (*geojson.Feature, error) {
 	electorate, ok := electorates[ElectorateID(id)]
 	if !ok {
-		return nil, fmt.Errorf("Electorate %v does not exist", id)
+		return nil, newUnknownElectorateError(string(id))
 	}
 	poly := electorate.polygons[z]
 	feature := ShpPolygonToGeojsonFeature(poly)
@@ -131,6 +131,15 @@ func cos(degree float64) float64 {
 	return math.Cos(degree * math.Pi / 180)
 }
 
+// distanceMetres returns the great-circle distance in metres between two
+// points, using the haversine formula.
+func distanceMetres(lng1, lat1, lng2, lat2 float64) float64 {
+	sinHalfDLat := sin((lat2 - lat1) / 2)
+	sinHalfDLng := sin((lng2 - lng1) / 2)
+	a := sinHalfDLat*sinHalfDLat + cos(lat1)*cos(lat2)*sinHalfDLng*sinHalfDLng
+	return 2 * EarthRadius * 1000 * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
 // calcMinSquareAreaEstimate returns a rough estimate of the area of a bounding box given by rect.
 func calcMinSquareAreaEstimate(rect *rtree.Rect) float64 {
 	long1 := rect.PointCoord(0)
@@ -176,6 +185,13 @@ type viewportResponse struct {
 	originalZoom int
 	zoom         ZoomLevel
 	electorates  []rtree.Spatial
+	fields       pollingPlaceFields
+	// What the features were made of, for responses which aren't GeoJSON,
+	// e.g. those of the gRPC API.
+	electorateIds      []string
+	labels             map[ElectorateID][][]float64
+	pollingPlaces      []int
+	pollingPlaceGroups []pollingPlaceGroup
 }
 
 func NewViewportResponse(rect *rtree.Rect, zoom ZoomLevel, originalZoom int) *viewportResponse {
@@ -199,8 +215,8 @@ func (vr *viewportResponse) populateElectorateIdsAndAreas() {
 	bboxArea := calcMinSquareAreaEstimate(vr.rect)
 	var ids []string
 	titleLocations := map[ElectorateID][][]float64{}
-	featuresFound := electorateTree.SearchIntersect(vr.rect)
-	for i, spatial := range featuresFound {
+	vr.electorates = searchElectorates("viewport", vr.rect)
+	for i, spatial := range vr.electorates {
 		electorate, ok := spatial.(*Electorate)
 		if !ok {
 			log.Printf("Couldn't convert spatial %v to electorate, viewport bbox: %v, zoom: %v", i, vr.BoundingBox, vr.zoom)
@@ -234,6 +250,8 @@ func (vr *viewportResponse) populateElectorateIdsAndAreas() {
 	electorateIdsFeature.Properties["type"] = TypeElectorateIds
 	electorateIdsFeature.Properties["electorates"] = ids
 	vr.AddFeature(electorateIdsFeature)
+	vr.electorateIds = ids
+	vr.labels = titleLocations
 
 	// For each electorate that had polygons large enough to show a title over them,
 	// add a single multipoint feature with the id and name of the electorate.
@@ -249,7 +267,9 @@ func (vr *viewportResponse) populateElectorateIdsAndAreas() {
 const MaxZoomLevelToIgnorePollingPlaces = 8
 const MinZoomLevelToShowUngroupedPollingPlaces = 14
 
-func (p *PollingPlace) toFeature() *geojson.Feature {
+// toFeature returns p as a point feature with the given fields as
+// properties.
+func (p *PollingPlace) toFeature(fields pollingPlaceFields) *geojson.Feature {
 	placeFeature := geojson.NewPointFeature([]float64{
 		float64(p.Lng),
 		float64(p.Lat),
@@ -258,8 +278,15 @@ func (p *PollingPlace) toFeature() *geojson.Feature {
 	placeFeature.Properties["type"] = TypePollingPlace
 	// NOTE: Needs to be overriden to provide any sensible value. Client should consider minZoom > 0 as a useful value.
 	placeFeature.Properties["minZoom"] = 0
-	// TODO this is way more than is needed client-side.. we should trim this. Possibly also the polling places go-data.
-	structs.FillMap(p, placeFeature.Properties)
+	if fields == nil {
+		structs.FillMap(p, placeFeature.Properties)
+		return placeFeature
+	}
+	for _, f := range structs.Fields(p) {
+		if fields[f.Name()] {
+			placeFeature.Properties[f.Name()] = f.Value()
+		}
+	}
 	return placeFeature
 }
 
@@ -298,43 +325,95 @@ func (placeGroup pollingPlaceGroup) toFeatureWithID(id string) *geojson.Feature
 	return placeGroupFeature
 }
 
-func (vr *viewportResponse) populatePollingPlaces() {
-	if vr.originalZoom <= MaxZoomLevelToIgnorePollingPlaces {
-		return
+// rectContains returns true if the point given by lng, lat is within rect.
+func rectContains(rect *rtree.Rect, lng, lat float64) bool {
+	minLng := rect.PointCoord(0)
+	minLat := rect.PointCoord(1)
+	return lng >= minLng && lng <= minLng+rect.LengthsCoord(0) &&
+		lat >= minLat && lat <= minLat+rect.LengthsCoord(1)
+}
+
+// viewportMinZoom returns the zoom level from which the polling place at
+// pIndex is shown individually in viewports. Polling places without a minimum
+// zoom stay clustered up to the highest clustering zoom level, so they're only
+// shown beyond it.
+func viewportMinZoom(pIndex int) int {
+	if minZoom, ok := pollingPlaceMinZoom[pIndex]; ok {
+		return minZoom
 	}
-	key := vr.originalZoom
-	if key > MinZoomLevelToShowUngroupedPollingPlaces {
-		key = MinZoomLevelToShowUngroupedPollingPlaces
+	return MinZoomLevelToShowUngroupedPollingPlaces + 1
+}
+
+// populatePollingPlaces adds the polling places and polling place groups
+// which are visible in the viewport at the requested zoom level, and returns
+// the indices of the polling places and the groups it added. It follows the
+// hierarchy computed by clusterPollingPlacesByPolygon: a group is only shown
+// at its own zoom level, while an individual polling place is shown at its
+// minimum zoom level and above. It relies on vr.electorates, so it must be
+// called after populateElectorateIdsAndAreas.
+func (vr *viewportResponse) populatePollingPlaces() ([]int, []pollingPlaceGroup) {
+	if vr.originalZoom <= MaxZoomLevelToIgnorePollingPlaces {
+		return nil, nil
 	}
-	featuresFound := polplaceTrees[key].SearchIntersect(vr.rect)
-	// debug:
-	// log.Printf("Found %v polling places", len(featuresFound))
-	for i, spatial := range featuresFound {
-		var place PollingPlace
-		pps, ok := spatial.(pollingPlaceSpatial)
+	var indices []int
+	var groups []pollingPlaceGroup
+	pplaceGroupIds := make(map[string]struct{})
+	for i, spatial := range vr.electorates {
+		e, ok := spatial.(*Electorate)
 		if !ok {
-			placeGroup, ok := spatial.(pollingPlaceGroup)
-			if !ok {
-				log.Printf("Couldn't convert spatial %v to PollingPlace or PollingPlaceGroup, "+
-					"viewport bbox: %v, zoom: %v", i, vr.BoundingBox, vr.zoom)
+			log.Printf("Couldn't convert spatial %v to electorate, viewport bbox: %v, zoom: %v", i, vr.BoundingBox, vr.zoom)
+			continue
+		}
+		for _, ep := range e.polygons[highestZoomLevel] {
+			for _, pIndex := range ep.pollingPlaces {
+				minZoom := viewportMinZoom(pIndex)
+				if minZoom > vr.originalZoom {
+					continue
+				}
+				place := pollingPlaces[pIndex]
+				if !rectContains(vr.rect, place.Lng, place.Lat) {
+					continue
+				}
+				feature := place.toFeature(vr.fields)
+				feature.Properties["minZoom"] = minZoom
+				vr.AddFeature(feature)
+				indices = append(indices, pIndex)
+			}
+		}
+		for _, pplaceGroup := range e.pplaceGrps {
+			if pplaceGroup.minZoom != vr.originalZoom {
 				continue
 			}
-			if vr.originalZoom >= MinZoomLevelToShowUngroupedPollingPlaces {
-				place = pollingPlaces[placeGroup.pollingPlaceIndices[0]]
-				vr.AddFeature(place.toFeature())
-			} else {
-				vr.AddFeature(placeGroup.toFeature())
+			if !rectContains(vr.rect, pplaceGroup.Lng, pplaceGroup.Lat) {
+				continue
 			}
-			continue
+			// Groups of small polygons may be shared between
+			// electorates, only add them once.
+			groupID := pplaceGroup.ID()
+			if _, ok := pplaceGroupIds[groupID]; ok {
+				continue
+			}
+			pplaceGroupIds[groupID] = struct{}{}
+			vr.AddFeature(pplaceGroup.toFeatureWithID(groupID))
+			groups = append(groups, pplaceGroup)
 		}
-		place = pps.PollingPlace
-		vr.AddFeature(place.toFeature())
 	}
+	return indices, groups
 }
 
-func queryViewport(rect *rtree.Rect, zoom ZoomLevel, originalZoom int) *viewportResponse {
+// IncludePollingPlaces is the value of the viewport 'include' parameter
+// which adds polling places and polling place groups to the response.
+const IncludePollingPlaces = "polling_places"
+
+// queryViewport returns the electorates in rect, and the polling places
+// with the given fields if includePollingPlaces is set.
+func queryViewport(rect *rtree.Rect, zoom ZoomLevel, originalZoom int, includePollingPlaces bool, fields pollingPlaceFields) *viewportResponse {
 	vr := NewViewportResponse(rect, zoom, originalZoom)
 	vr.populateElectorateIdsAndAreas()
+	if includePollingPlaces {
+		vr.fields = fields
+		vr.pollingPlaces, vr.pollingPlaceGroups = vr.populatePollingPlaces()
+	}
 	return vr
 }
 
@@ -342,11 +421,26 @@ func queryViewport(rect *rtree.Rect, zoom ZoomLevel, originalZoom int) *viewport
 // too large to send in one response.
 const MaxZoomForAllElectorates = 8
 
-func queryElectorates(zoom ZoomLevel, ids string) (*geojson.FeatureCollection, error) {
+// canonicalElectorateIDs returns the comma separated electorate IDs sorted,
+// as queryElectorates would return them, for use in cache keys.
+func canonicalElectorateIDs(ids string) string {
+	if strings.ToLower(ids) == "all" {
+		return "all"
+	}
+	electorateIds := strings.Split(ids, ",")
+	sort.Strings(electorateIds)
+	return strings.Join(electorateIds, ",")
+}
+
+// queryElectorateIDs returns the electorate IDs of an 'ids' parameter in the
+// order they're returned in, checking that each one exists so that streamed
+// responses can't fail part way through.
+func queryElectorateIDs(zoom ZoomLevel, ids string) ([]ElectorateID, error) {
 	var electorateIds []string
 	if strings.ToLower(ids) == "all" {
 		if int(zoom) > MaxZoomForAllElectorates {
-			return nil, fmt.Errorf("ids=all isn't allowed at zoom level %v", zoom)
+			return nil, newBadRequestError(CodeAllNotAllowedAtZoom, "ids", ids,
+				"ids=all isn't allowed at zoom level %v, the maximum is %v", zoom, MaxZoomForAllElectorates)
 		}
 		for id := range electorates {
 			electorateIds = append(electorateIds, string(id))
@@ -359,23 +453,48 @@ func queryElectorates(zoom ZoomLevel, ids string) (*geojson.FeatureCollection, e
 	// Since this should be a relatively large response payload, ensure the order is identical,
 	// making life easier for any caching level between this app and the consumer.
 	sort.Strings(electorateIds)
-	fc := geojson.NewFeatureCollection()
+	result := make([]ElectorateID, len(electorateIds))
+	for i, id := range electorateIds {
+		if _, ok := electorates[ElectorateID(id)]; !ok {
+			return nil, newUnknownElectorateError(id)
+		}
+		result[i] = ElectorateID(id)
+	}
+	return result, nil
+}
+
+// electoratesBoundingBox returns the GeoJSON bbox of the given electorates,
+// which must exist.
+func electoratesBoundingBox(ids []ElectorateID) []float64 {
 	var fcBbox *shp.Box
+	for _, id := range ids {
+		bbox := electorates[id].bbox
+		if fcBbox == nil {
+			fcBbox = &shp.Box{MinX: bbox.MinX, MinY: bbox.MinY, MaxX: bbox.MaxX, MaxY: bbox.MaxY}
+		} else {
+			fcBbox.Extend(*bbox)
+		}
+	}
+	if fcBbox == nil {
+		return nil
+	}
+	return []float64{fcBbox.MinX, fcBbox.MinY, fcBbox.MaxX, fcBbox.MaxY}
+}
+
+func queryElectorates(zoom ZoomLevel, ids string) (*geojson.FeatureCollection, error) {
+	electorateIds, err := queryElectorateIDs(zoom, ids)
+	if err != nil {
+		return nil, err
+	}
+	fc := geojson.NewFeatureCollection()
 	for _, id := range electorateIds {
-		f, err := electorateToGeoJsonFeature(ElectorateID(id), zoom)
+		f, err := electorateToGeoJsonFeature(id, zoom)
 		if err != nil {
-			return nil, fmt.Errorf("Failed retreiving electorate %v details: %v", id, err)
+			return nil, err
 		}
 		fc.AddFeature(f)
-		fb := f.BoundingBox
-		fBBox := shp.Box{MinX: fb[0], MinY: fb[1], MaxX: fb[2], MaxY: fb[3]}
-		if fcBbox == nil {
-			fcBbox = &fBBox
-		} else {
-			fcBbox.Extend(fBBox)
-		}
 	}
-	fc.BoundingBox = []float64{fcBbox.MinX, fcBbox.MinY, fcBbox.MaxX, fcBbox.MaxY}
+	fc.BoundingBox = electoratesBoundingBox(electorateIds)
 	return fc, nil
 }
 
@@ -388,65 +507,172 @@ func queryElectorates(zoom ZoomLevel, ids string) (*geojson.FeatureCollection, e
 // Pamela Avenue Peakhurst -> Banks
 
 func queryLocation(lng, lat float64) string {
-	rect := rtree.Point{lng, lat}.ToRect(1e-6)
+	electorate := locateElectorate(lng, lat)
+	if electorate == nil {
+		return ""
+	}
+	return electorate.name
+}
+
+// searchElectorates returns the electorates whose bounding box intersects
+// rect, recording the search in the metrics by query.
+func searchElectorates(query string, rect *rtree.Rect) []rtree.Spatial {
 	spatials := electorateTree.SearchIntersect(rect)
+	rtreeSearches.WithLabelValues(query).Inc()
+	rtreeHits.WithLabelValues(query).Add(float64(len(spatials)))
+	return spatials
+}
+
+// locateElectorate returns the electorate containing the given point, or nil
+// if the point isn't in any electorate.
+func locateElectorate(lng, lat float64) *Electorate {
+	electorate, _ := locateElectoratePolygon(lng, lat)
+	return electorate
+}
+
+// locateElectoratePolygon returns the electorate containing the given point
+// and the polygon of it which does, or nils if the point isn't in any
+// electorate.
+func locateElectoratePolygon(lng, lat float64) (*Electorate, *ElectoratePolygon) {
+	rect := rtree.Point{lng, lat}.ToRect(1e-6)
+	spatials := searchElectorates("location", rect)
 	for _, spatial := range spatials {
 		electorate, ok := spatial.(*Electorate)
 		if !ok {
 			continue
 		}
 		for _, electoratePolygon := range electorate.polygons[highestZoomLevel] {
+			pointInPolygonEvaluations.Inc()
 			if in := inside(shp.Point{X: lng, Y: lat}, *electoratePolygon.Polygon); in {
-				return electorate.name
+				return electorate, electoratePolygon
 			}
 		}
 	}
-	return ""
+	return nil, nil
 }
 
-// queryPollingPlaces returns a point-feature-collection of clusters and
-// polygons for a given list of comma separated electorate IDs.
-func queryPollingPlaces(ids string) (*geojson.FeatureCollection, error) {
-	var electorateIds []string
-	for _, id := range strings.Split(ids, ",") {
-		electorateIds = append(electorateIds, id)
+// selectPollingPlaces returns the indices in pollingPlaces of the polling
+// places of the given comma separated electorate IDs, or anywhere if ids is
+// empty, which match filter.
+func selectPollingPlaces(ids string, filter *pollingPlaceFilter) ([]int, error) {
+	var indices []int
+	if ids == "" {
+		for i := range pollingPlaces {
+			indices = append(indices, i)
+		}
+		return filterPollingPlaces(indices, filter), nil
 	}
 	// Not as large as the electore query response, still cachable so
 	// sorting IDs.
+	electorateIds := strings.Split(ids, ",")
 	sort.Strings(electorateIds)
-	fc := geojson.NewFeatureCollection()
-	var points []shp.Point
-	pplaceGroupIds := make(map[string]struct{})
 	for _, id := range electorateIds {
 		e := electorates[ElectorateID(id)]
 		if e == nil {
-			return nil, fmt.Errorf("Electorate not found for ID '%v'", id)
+			return nil, newUnknownElectorateError(id)
 		}
-		// Add features for polling places.
-		for _, ep := range e.polygons[highestZoomLevel] {
-			for _, pIndex := range ep.pollingPlaces {
-				pollingPlace := pollingPlaces[pIndex]
-				feature := pollingPlace.toFeature()
-				// override minZoom, as it's relevant for the
-				// client.
-				feature.Properties["minZoom"] =
-					pollingPlaceMinZoom[pIndex]
-				fc.AddFeature(feature)
-				points = append(points, shp.Point{X: pollingPlace.Lng, Y: pollingPlace.Lat})
+		indices = append(indices, filterPollingPlaces(electoratePollingPlaces(e), filter)...)
+	}
+	return indices, nil
+}
+
+// queryPollingPlaces returns a point-feature-collection of clusters and
+// polygons for a given list of comma separated electorate IDs, or of the
+// polling places anywhere if ids is empty, with the given fields of polling
+// places. Only polling places matching filter are included, and clusters only
+// if it's empty, as they group every polling place of their polygons.
+func queryPollingPlaces(ids string, filter *pollingPlaceFilter, fields pollingPlaceFields) (*geojson.FeatureCollection, error) {
+	indices, err := selectPollingPlaces(ids, filter)
+	if err != nil {
+		return nil, err
+	}
+	fc := geojson.NewFeatureCollection()
+	var points []shp.Point
+	for _, pIndex := range indices {
+		pollingPlace := pollingPlaces[pIndex]
+		feature := pollingPlace.toFeature(fields)
+		// override minZoom, as it's relevant for the client.
+		feature.Properties["minZoom"] = pollingPlaceMinZoom[pIndex]
+		fc.AddFeature(feature)
+		points = append(points, shp.Point{X: pollingPlace.Lng, Y: pollingPlace.Lat})
+	}
+	if ids != "" && filter.isEmpty() {
+		electorateIds := strings.Split(ids, ",")
+		sort.Strings(electorateIds)
+		pplaceGroupIds := make(map[string]struct{})
+		for _, id := range electorateIds {
+			// Add features for clustering polling places.
+			for _, pplaceGroup := range electorates[ElectorateID(id)].pplaceGrps {
+				groupID := pplaceGroup.ID()
+				if _, ok := pplaceGroupIds[groupID]; ok {
+					continue
+				}
+				pplaceGroupIds[groupID] = struct{}{}
+				fc.AddFeature(pplaceGroup.toFeatureWithID(groupID))
+				points = append(points, shp.Point{X: pplaceGroup.Lng, Y: pplaceGroup.Lat})
 			}
 		}
-		// Add features for clustering polling places.
-		for _, pplaceGroup := range e.pplaceGrps {
-			groupID := pplaceGroup.ID()
-			if _, ok := pplaceGroupIds[groupID]; ok {
-				continue
-			}
-			pplaceGroupIds[groupID] = struct{}{}
-			fc.AddFeature(pplaceGroup.toFeatureWithID(groupID))
-			points = append(points, shp.Point{X: pplaceGroup.Lng, Y: pplaceGroup.Lat})
+	}
+	if len(points) > 0 {
+		fcBbox := shp.BBoxFromPoints(points)
+		fc.BoundingBox = []float64{fcBbox.MinX, fcBbox.MinY, fcBbox.MaxX, fcBbox.MaxY}
+	}
+	return fc, nil
+}
+
+type pollingPlaceDistance struct {
+	index    int
+	distance float64
+}
+
+type byDistance []pollingPlaceDistance
+
+func (d byDistance) Len() int           { return len(d) }
+func (d byDistance) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byDistance) Less(i, j int) bool { return d[i].distance < d[j].distance }
+
+// queryNearestPollingPlaces returns a point-feature-collection of the k
+// polling places closest to the given location, ordered by distance. If
+// sameElectorate is set, only polling places in the electorate containing the
+// location are considered.
+func queryNearestPollingPlaces(lng, lat float64, k int, sameElectorate bool, fields pollingPlaceFields) (*geojson.FeatureCollection, error) {
+	var electorateID ElectorateID
+	if sameElectorate {
+		name := queryLocation(lng, lat)
+		if name == "" {
+			return nil, newLocationNotInElectorateError(fmt.Sprintf("%v,%v", lat, lng))
+		}
+		electorateID = ElectorateID(strings.ToLower(name))
+	}
+	// There are only several thousand polling places, so a linear scan
+	// is cheap enough and gives exact great-circle distances.
+	var distances []pollingPlaceDistance
+	for i, p := range pollingPlaces {
+		if sameElectorate && ElectorateID(strings.ToLower(p.DivisionName)) != electorateID {
+			continue
 		}
+		distances = append(distances, pollingPlaceDistance{
+			index:    i,
+			distance: distanceMetres(lng, lat, p.Lng, p.Lat),
+		})
+	}
+	sort.Stable(byDistance(distances))
+	if len(distances) > k {
+		distances = distances[:k]
+	}
+	fc := geojson.NewFeatureCollection()
+	var points []shp.Point
+	for _, d := range distances {
+		pollingPlace := pollingPlaces[d.index]
+		feature := pollingPlace.toFeature(fields)
+		feature.Properties["minZoom"] = pollingPlaceMinZoom[d.index]
+		feature.Properties["distance_m"] = math.Floor(d.distance + 0.5)
+		fc.AddFeature(feature)
+		points = append(points, shp.Point{X: pollingPlace.Lng, Y: pollingPlace.Lat})
+	}
+	if len(points) > 0 {
+		fcBbox := shp.BBoxFromPoints(points)
+		fc.BoundingBox = []float64{fcBbox.MinX, fcBbox.MinY, fcBbox.MaxX, fcBbox.MaxY}
 	}
-	fcBbox := shp.BBoxFromPoints(points)
-	fc.BoundingBox = []float64{fcBbox.MinX, fcBbox.MinY, fcBbox.MaxX, fcBbox.MaxY}
 	return fc, nil
 }
@@ -37,18 +37,25 @@ import (
 const DataFolder = "dist/national_elb"
 
 func initSpatial() {
-	initZoomBuckets()
-	initElectorates()
+	startInit()
+	runInitPhase("zoom_buckets", initZoomBuckets)
+	runInitPhase("electorates", initElectorates)
 	// TODO: the 'ByElectorates' and 'ByPolygon' clustering methods below
 	// require a single rtree of polling places.  We could simplify
 	// initPollingPlaces for this purpose, as it's no longer used for
 	// viewport queries.
-	initPollingPlaces()
+	runInitPhase("polling_places", initPollingPlaces)
 	// Note order is important, as in the new clustering scheme polling
 	// places rely on electorate data (shapefiles etc.) to be loaded.
-	initPollingPlacesByElectorates()
-	clusterPollingPlacesByPolygon()
-	unclusterSmallIdenticalClusters()
+	runInitPhase("polling_places_by_electorates", initPollingPlacesByElectorates)
+	runInitPhase("cluster_polling_places", clusterPollingPlacesByPolygon)
+	runInitPhase("uncluster_small_identical_clusters", unclusterSmallIdenticalClusters)
+	runInitPhase("search_index", initSearchIndex)
+	runInitPhase("area_indexes", initAreaIndexes)
+	runInitPhase("dataset_version", func() {
+		datasetVersion = computeDatasetVersion()
+	})
+	finishInit()
 }
 
 // ZoomLevel means one of a set of consumer viewport's zoom level when viewing
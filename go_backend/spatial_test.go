@@ -0,0 +1,142 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	rtree "github.com/dhconnelly/rtreego"
+)
+
+func TestDistanceMetres(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lng1, lat1, lng2, lat2 float64
+		expected               float64
+	}{
+		{"same point", 151.2152967, -33.8567844, 151.2152967, -33.8567844, 0},
+		// One degree of latitude along a meridian.
+		{"one degree", 149, -35, 149, -36, 111319},
+		// Sydney Opera House to Parliament House, Canberra: roughly 250km.
+		{"sydney to canberra", 151.2153, -33.8568, 149.1244, -35.3082, 250000},
+	}
+	for _, tt := range tests {
+		d := distanceMetres(tt.lng1, tt.lat1, tt.lng2, tt.lat2)
+		// Allow for a 1% error, the earth isn't a perfect sphere.
+		if math.Abs(d-tt.expected) > tt.expected*0.01+1 {
+			t.Errorf("%s: expected ~%v metres, got %v", tt.name, tt.expected, d)
+		}
+	}
+}
+
+func TestPopulatePollingPlacesNoDuplicates(t *testing.T) {
+	savedElectorates, savedMinZoom := electorates, pollingPlaceMinZoom
+	defer func() { electorates, pollingPlaceMinZoom = savedElectorates, savedMinZoom }()
+	// Allawah is clustered at every zoom level so has no minimum zoom,
+	// Allawah South is shown individually from zoom 12.
+	group := func(zoom int, pIndices ...int) pollingPlaceGroup {
+		return pollingPlaceGroup{pollingPlaceIndices: pIndices, Lng: 151.1, Lat: -33.9, minZoom: zoom, divisionName: "banks"}
+	}
+	banks := &Electorate{
+		id: "banks",
+		polygons: map[ZoomLevel][]*ElectoratePolygon{
+			highestZoomLevel: {{pollingPlaces: []int{0, 1}}},
+		},
+		pplaceGrps: []pollingPlaceGroup{group(9, 0, 1), group(10, 0, 1), group(11, 0, 1), group(14, 0)},
+	}
+	electorates = map[ElectorateID]*Electorate{"banks": banks}
+	pollingPlaceMinZoom = map[int]int{1: 12}
+	rect, err := rtree.NewRect(rtree.Point{140, -40}, []float64{20, 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for zoom := MaxZoomLevelToIgnorePollingPlaces + 1; zoom <= MinZoomLevelToShowUngroupedPollingPlaces+1; zoom++ {
+		vr := NewViewportResponse(rect, 5, zoom)
+		vr.electorates = []rtree.Spatial{banks}
+		vr.populatePollingPlaces()
+		individual := make(map[int]bool)
+		grouped := make(map[int]bool)
+		for _, f := range vr.Features {
+			switch f.Properties["type"] {
+			case TypePollingPlace:
+				individual[f.Properties["PollingPlaceId"].(int)] = true
+			case TypePollingPlaceGroup:
+				// Group IDs are the zoom level followed by the
+				// polling place IDs.
+				ids := strings.SplitN(f.ID.(string), "_", 2)[1]
+				for _, id := range strings.Split(ids, ",") {
+					pid, _ := strconv.Atoi(id)
+					grouped[pid] = true
+				}
+			}
+		}
+		for id := range individual {
+			if grouped[id] {
+				t.Errorf("Zoom %v: polling place %v is shown both individually and in a group", zoom, id)
+			}
+		}
+		if zoom > MinZoomLevelToShowUngroupedPollingPlaces && len(individual) != 2 {
+			t.Errorf("Zoom %v: expected both polling places individually, got %v", zoom, individual)
+		}
+	}
+}
+
+func TestQueryNearestPollingPlaces(t *testing.T) {
+	allawah := pollingPlaces[0]
+	fc, err := queryNearestPollingPlaces(allawah.Lng, allawah.Lat, 5, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 5 {
+		t.Fatalf("Expected 5 polling places, got %v", len(fc.Features))
+	}
+	if id := fc.Features[0].ID; id != strconv.Itoa(allawah.PollingPlaceId) || fc.Features[0].Properties["distance_m"] != 0.0 {
+		t.Errorf("Expected Allawah first, got %v at %v metres", id, fc.Features[0].Properties["distance_m"])
+	}
+	for i := 1; i < len(fc.Features); i++ {
+		if fc.Features[i].Properties["distance_m"].(float64) < fc.Features[i-1].Properties["distance_m"].(float64) {
+			t.Errorf("Expected polling places ordered by distance, got %v after %v",
+				fc.Features[i].Properties["distance_m"], fc.Features[i-1].Properties["distance_m"])
+		}
+	}
+
+	withSquareElectorates(func() {
+		// Only polling places listed under the electorate containing the
+		// location are considered.
+		electorates["east"].name = "Banks"
+		fc, err := queryNearestPollingPlaces(150.5, -34.5, 3, true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fc.Features) != 3 {
+			t.Fatalf("Expected 3 polling places, got %v", len(fc.Features))
+		}
+		for _, f := range fc.Features {
+			if f.Properties["DivisionName"] != "Banks" {
+				t.Errorf("Expected polling places in Banks, got %v", f.Properties["DivisionName"])
+			}
+		}
+		_, err = queryNearestPollingPlaces(155, -34.5, 3, true, nil)
+		if apiErr, ok := err.(*APIError); !ok || apiErr.Code != CodeLocationNotInElectorate {
+			t.Errorf("Expected a %v error, got %v", CodeLocationNotInElectorate, err)
+		}
+	})
+}
@@ -0,0 +1,445 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// sqlitePageSize is the page size of databases written by writeSQLite.
+const sqlitePageSize = 4096
+
+// sqliteTable is a table of a database written by writeSQLite. Values are
+// nil, int64, float64, string or []byte.
+type sqliteTable struct {
+	Name string
+	// SQL is the CREATE TABLE statement.
+	SQL string
+	// RowIDColumn is the index of the column declared INTEGER PRIMARY KEY,
+	// which is an alias for the rowid, or -1 if there is none and rows get
+	// rowids from 1.
+	RowIDColumn int
+	Rows        [][]interface{}
+	// Indexes are the columns of the automatic indexes SQLite creates for
+	// the PRIMARY KEY and UNIQUE constraints of the table, in the order
+	// they're declared.
+	Indexes [][]int
+}
+
+// sqliteHeader are the fields of the database header which aren't fixed.
+type sqliteHeader struct {
+	ApplicationID uint32
+	UserVersion   uint32
+}
+
+// sqliteRow is a row of a table, or an entry of an index.
+type sqliteRow struct {
+	rowID  int64
+	values []interface{}
+}
+
+// sqliteWriter lays out the pages of a database in memory, page i+1 being
+// pages[i]. Page 1 is reserved for the schema table.
+type sqliteWriter struct {
+	pages [][]byte
+}
+
+// writeSQLite writes a SQLite 3 database of tables to w. It's only as
+// capable as exporting needs: the schema and every index have to fit in a
+// single page.
+func writeSQLite(w io.Writer, header sqliteHeader, tables []*sqliteTable) error {
+	db := &sqliteWriter{pages: [][]byte{nil}}
+	var schema []sqliteRow
+	for _, t := range tables {
+		rows := make([]sqliteRow, len(t.Rows))
+		for i, values := range t.Rows {
+			rows[i] = sqliteRow{rowID: int64(i + 1), values: values}
+			if t.RowIDColumn >= 0 {
+				rows[i].rowID = values[t.RowIDColumn].(int64)
+				// The rowid alias is stored as NULL.
+				rows[i].values = append([]interface{}{}, values...)
+				rows[i].values[t.RowIDColumn] = nil
+			}
+		}
+		sort.Sort(sqliteRowsByID(rows))
+		root, err := db.writeTable(rows)
+		if err != nil {
+			return err
+		}
+		schema = append(schema, sqliteRow{values: []interface{}{"table", t.Name, t.Name, int64(root), t.SQL}})
+		for i, columns := range t.Indexes {
+			entries := make([]sqliteRow, len(rows))
+			for j, row := range rows {
+				for _, c := range columns {
+					entries[j].values = append(entries[j].values, row.values[c])
+				}
+				entries[j].values = append(entries[j].values, row.rowID)
+			}
+			sort.Sort(sqliteRowsByValues(entries))
+			root, err := db.writeIndex(entries)
+			if err != nil {
+				return fmt.Errorf("index %v of %v: %v", i+1, t.Name, err)
+			}
+			name := fmt.Sprintf("sqlite_autoindex_%v_%v", t.Name, i+1)
+			schema = append(schema, sqliteRow{values: []interface{}{"index", name, t.Name, int64(root), nil}})
+		}
+	}
+	var cells [][]byte
+	for i := range schema {
+		cells = append(cells, db.tableLeafCell(int64(i+1), appendSQLiteRecord(nil, schema[i].values)))
+	}
+	if !sqlitePageFits(100+8, cells) {
+		return fmt.Errorf("the schema doesn't fit in a page")
+	}
+	db.pages[0] = sqlitePage(100, 0x0d, cells, 0)
+	db.writeDatabaseHeader(header)
+	for _, page := range db.pages {
+		if _, err := w.Write(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *sqliteWriter) writeDatabaseHeader(header sqliteHeader) {
+	h := db.pages[0][:100]
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], sqlitePageSize)
+	// Legacy journalling, no reserved bytes and the fixed payload fractions.
+	copy(h[18:], []byte{1, 1, 0, 64, 32, 32})
+	// File change counter and database size in pages.
+	binary.BigEndian.PutUint32(h[24:], 1)
+	binary.BigEndian.PutUint32(h[28:], uint32(len(db.pages)))
+	// Schema cookie, schema format 4 and UTF-8 text.
+	binary.BigEndian.PutUint32(h[40:], 1)
+	binary.BigEndian.PutUint32(h[44:], 4)
+	binary.BigEndian.PutUint32(h[56:], 1)
+	binary.BigEndian.PutUint32(h[60:], header.UserVersion)
+	binary.BigEndian.PutUint32(h[68:], header.ApplicationID)
+	// Version-valid-for, matching the change counter, and the version of
+	// SQLite the file format corresponds to.
+	binary.BigEndian.PutUint32(h[92:], 1)
+	binary.BigEndian.PutUint32(h[96:], 3008000)
+}
+
+// addPage appends page, returning its page number.
+func (db *sqliteWriter) addPage(page []byte) uint32 {
+	db.pages = append(db.pages, page)
+	return uint32(len(db.pages))
+}
+
+// writeTable writes a table b-tree of rows, sorted by rowid, returning its
+// root page.
+func (db *sqliteWriter) writeTable(rows []sqliteRow) (uint32, error) {
+	var cells [][]byte
+	for _, row := range rows {
+		cells = append(cells, db.tableLeafCell(row.rowID, appendSQLiteRecord(nil, row.values)))
+	}
+	type child struct {
+		page  uint32
+		maxID int64
+	}
+	// Pack the leaves.
+	var children []child
+	for start := 0; start < len(cells) || len(children) == 0; {
+		end := start
+		for end < len(cells) && sqlitePageFits(8, cells[start:end+1]) {
+			end++
+		}
+		page := db.addPage(sqlitePage(0, 0x0d, cells[start:end], 0))
+		var maxID int64
+		if end > 0 {
+			maxID = rows[end-1].rowID
+		}
+		children = append(children, child{page, maxID})
+		start = end
+	}
+	// Then interior pages over them, until there's a single root. Spreading
+	// children evenly leaves every interior page with at least one cell, as
+	// the rightmost child is in its header.
+	const maxChildren = (sqlitePageSize-12)/(2+4+9) + 1
+	for len(children) > 1 {
+		var parents []child
+		n := (len(children) + maxChildren - 1) / maxChildren
+		for i := 0; i < n; i++ {
+			group := children[i*len(children)/n : (i+1)*len(children)/n]
+			var cells [][]byte
+			for _, c := range group[:len(group)-1] {
+				cell := make([]byte, 4, 13)
+				binary.BigEndian.PutUint32(cell, c.page)
+				cells = append(cells, appendSQLiteVarint(cell, uint64(c.maxID)))
+			}
+			last := group[len(group)-1]
+			parents = append(parents, child{db.addPage(sqlitePage(0, 0x05, cells, last.page)), last.maxID})
+		}
+		children = parents
+	}
+	return children[0].page, nil
+}
+
+// writeIndex writes an index b-tree of entries, sorted by their values, in a
+// single page, returning its page number.
+func (db *sqliteWriter) writeIndex(entries []sqliteRow) (uint32, error) {
+	var cells [][]byte
+	for _, entry := range entries {
+		payload := appendSQLiteRecord(nil, entry.values)
+		if len(payload) > sqliteMaxLocal(false) {
+			return 0, fmt.Errorf("index entry too large")
+		}
+		cells = append(cells, append(appendSQLiteVarint(nil, uint64(len(payload))), payload...))
+	}
+	if !sqlitePageFits(8, cells) {
+		return 0, fmt.Errorf("index doesn't fit in a page")
+	}
+	return db.addPage(sqlitePage(0, 0x0a, cells, 0)), nil
+}
+
+// sqliteMaxLocal returns the most payload of a cell of a table leaf or an
+// index page stored in the page itself.
+func sqliteMaxLocal(table bool) int {
+	if table {
+		return sqlitePageSize - 35
+	}
+	return (sqlitePageSize-12)*64/255 - 23
+}
+
+// tableLeafCell returns the cell of a table leaf for a row, writing any
+// payload which doesn't fit in the page to overflow pages.
+func (db *sqliteWriter) tableLeafCell(rowID int64, payload []byte) []byte {
+	cell := appendSQLiteVarint(nil, uint64(len(payload)))
+	cell = appendSQLiteVarint(cell, uint64(rowID))
+	local := len(payload)
+	if local > sqliteMaxLocal(true) {
+		minLocal := (sqlitePageSize-12)*32/255 - 23
+		local = minLocal + (len(payload)-minLocal)%(sqlitePageSize-4)
+		if local > sqliteMaxLocal(true) {
+			local = minLocal
+		}
+	}
+	cell = append(cell, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+	// Overflow pages are numbered in the order they're chained.
+	overflow := payload[local:]
+	first := uint32(len(db.pages) + 1)
+	for len(overflow) > 0 {
+		page := make([]byte, sqlitePageSize)
+		n := copy(page[4:], overflow)
+		overflow = overflow[n:]
+		if len(overflow) > 0 {
+			binary.BigEndian.PutUint32(page, uint32(len(db.pages)+2))
+		}
+		db.addPage(page)
+	}
+	var next [4]byte
+	binary.BigEndian.PutUint32(next[:], first)
+	return append(cell, next[:]...)
+}
+
+// sqlitePageFits reports whether cells fit in a page after a header of
+// headerSize bytes.
+func sqlitePageFits(headerSize int, cells [][]byte) bool {
+	size := headerSize
+	for _, cell := range cells {
+		size += 2 + len(cell)
+	}
+	return size <= sqlitePageSize
+}
+
+// sqlitePage returns a b-tree page of the given type with cells, in order,
+// its header starting at offset. rightChild is only used by interior pages.
+func sqlitePage(offset int, pageType byte, cells [][]byte, rightChild uint32) []byte {
+	page := make([]byte, sqlitePageSize)
+	header := page[offset:]
+	header[0] = pageType
+	binary.BigEndian.PutUint16(header[3:], uint16(len(cells)))
+	pointers := header[8:]
+	if pageType == 0x02 || pageType == 0x05 {
+		binary.BigEndian.PutUint32(header[8:], rightChild)
+		pointers = header[12:]
+	}
+	content := sqlitePageSize
+	for i, cell := range cells {
+		content -= len(cell)
+		copy(page[content:], cell)
+		binary.BigEndian.PutUint16(pointers[2*i:], uint16(content))
+	}
+	binary.BigEndian.PutUint16(header[5:], uint16(content))
+	return page
+}
+
+// appendSQLiteVarint appends v in SQLite's big-endian variable length
+// encoding.
+func appendSQLiteVarint(b []byte, v uint64) []byte {
+	var buf [9]byte
+	if v > 1<<56-1 {
+		buf[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(b, buf[:]...)
+	}
+	n := 9
+	for {
+		n--
+		buf[n] = byte(v&0x7f) | 0x80
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	buf[8] &= 0x7f
+	return append(b, buf[n:]...)
+}
+
+// sqliteSerialType returns the serial type of value in a record, and how
+// many bytes of its content are stored.
+func sqliteSerialType(value interface{}) (uint64, int) {
+	switch v := value.(type) {
+	case nil:
+		return 0, 0
+	case int64:
+		switch {
+		case v == 0:
+			return 8, 0
+		case v == 1:
+			return 9, 0
+		case v >= math.MinInt8 && v <= math.MaxInt8:
+			return 1, 1
+		case v >= math.MinInt16 && v <= math.MaxInt16:
+			return 2, 2
+		case v >= -1<<23 && v < 1<<23:
+			return 3, 3
+		case v >= math.MinInt32 && v <= math.MaxInt32:
+			return 4, 4
+		case v >= -1<<47 && v < 1<<47:
+			return 5, 6
+		}
+		return 6, 8
+	case float64:
+		return 7, 8
+	case string:
+		return uint64(len(v))*2 + 13, len(v)
+	case []byte:
+		return uint64(len(v))*2 + 12, len(v)
+	}
+	panic(fmt.Sprintf("unsupported SQLite value %T", value))
+}
+
+// appendSQLiteRecord appends values in the record format.
+func appendSQLiteRecord(b []byte, values []interface{}) []byte {
+	var types []byte
+	for _, value := range values {
+		serialType, _ := sqliteSerialType(value)
+		types = appendSQLiteVarint(types, serialType)
+	}
+	// The header's size includes its own varint.
+	headerSize := len(types) + 1
+	if n := len(appendSQLiteVarint(nil, uint64(headerSize))); n > 1 {
+		headerSize = len(types) + n
+	}
+	b = appendSQLiteVarint(b, uint64(headerSize))
+	b = append(b, types...)
+	for _, value := range values {
+		_, n := sqliteSerialType(value)
+		switch v := value.(type) {
+		case int64:
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], uint64(v))
+			b = append(b, buf[8-n:]...)
+		case float64:
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+			b = append(b, buf[:]...)
+		case string:
+			b = append(b, v...)
+		case []byte:
+			b = append(b, v...)
+		}
+	}
+	return b
+}
+
+// compareSQLiteValues orders values as SQLite does with the BINARY
+// collation: NULLs, numbers, text and then blobs.
+func compareSQLiteValues(a, b interface{}) int {
+	class := func(v interface{}) int {
+		switch v.(type) {
+		case nil:
+			return 0
+		case int64, float64:
+			return 1
+		case string:
+			return 2
+		}
+		return 3
+	}
+	if ca, cb := class(a), class(b); ca != cb {
+		return ca - cb
+	}
+	switch a := a.(type) {
+	case int64, float64:
+		fa, fb := sqliteNumber(a), sqliteNumber(b)
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	case string:
+		return bytes.Compare([]byte(a), []byte(b.(string)))
+	case []byte:
+		return bytes.Compare(a, b.([]byte))
+	}
+	return 0
+}
+
+func sqliteNumber(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+type sqliteRowsByID []sqliteRow
+
+func (r sqliteRowsByID) Len() int           { return len(r) }
+func (r sqliteRowsByID) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r sqliteRowsByID) Less(i, j int) bool { return r[i].rowID < r[j].rowID }
+
+type sqliteRowsByValues []sqliteRow
+
+func (r sqliteRowsByValues) Len() int      { return len(r) }
+func (r sqliteRowsByValues) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r sqliteRowsByValues) Less(i, j int) bool {
+	for k := range r[i].values {
+		if c := compareSQLiteValues(r[i].values[k], r[j].values[k]); c != 0 {
+			return c < 0
+		}
+	}
+	return false
+}
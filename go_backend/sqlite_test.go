@@ -0,0 +1,280 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// sqliteReader reads back the pages of a database written by writeSQLite,
+// following the file format rather than the writer.
+type sqliteReader struct {
+	t  *testing.T
+	db []byte
+}
+
+// readSQLiteVarint returns the varint at the start of b and its length.
+func readSQLiteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 8; i++ {
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v<<8 | uint64(b[8]), 9
+}
+
+// page returns page n and the b-tree page header, which follows the
+// database header on page 1.
+func (r *sqliteReader) page(n uint32) ([]byte, []byte) {
+	if n < 1 || int(n)*sqlitePageSize > len(r.db) {
+		r.t.Fatalf("Page %v is out of range", n)
+	}
+	page := r.db[(n-1)*sqlitePageSize : n*sqlitePageSize]
+	if n == 1 {
+		return page, page[100:]
+	}
+	return page, page
+}
+
+// cells returns the cells of a b-tree page, in order.
+func (r *sqliteReader) cells(page, header []byte) [][]byte {
+	count := int(binary.BigEndian.Uint16(header[3:]))
+	pointers := header[8:]
+	if header[0] == 0x02 || header[0] == 0x05 {
+		pointers = header[12:]
+	}
+	cells := make([][]byte, count)
+	for i := range cells {
+		cells[i] = page[binary.BigEndian.Uint16(pointers[2*i:]):]
+	}
+	return cells
+}
+
+// rows returns the rows of the table b-tree rooted at page n, in the order
+// of the tree.
+func (r *sqliteReader) rows(n uint32) []sqliteRow {
+	page, header := r.page(n)
+	var rows []sqliteRow
+	switch header[0] {
+	case 0x05:
+		for _, cell := range r.cells(page, header) {
+			rows = append(rows, r.rows(binary.BigEndian.Uint32(cell))...)
+		}
+		return append(rows, r.rows(binary.BigEndian.Uint32(header[8:]))...)
+	case 0x0d:
+		for _, cell := range r.cells(page, header) {
+			size, n := readSQLiteVarint(cell)
+			rowID, m := readSQLiteVarint(cell[n:])
+			rows = append(rows, sqliteRow{rowID: int64(rowID), values: r.record(r.payload(cell[n+m:], int(size)))})
+		}
+		return rows
+	}
+	r.t.Fatalf("Page %v isn't a table b-tree page: %x", n, header[0])
+	return nil
+}
+
+// indexEntries returns the entries of the index leaf page n.
+func (r *sqliteReader) indexEntries(n uint32) [][]interface{} {
+	page, header := r.page(n)
+	if header[0] != 0x0a {
+		r.t.Fatalf("Page %v isn't an index leaf page: %x", n, header[0])
+	}
+	var entries [][]interface{}
+	for _, cell := range r.cells(page, header) {
+		size, n := readSQLiteVarint(cell)
+		entries = append(entries, r.record(cell[n:n+int(size)]))
+	}
+	return entries
+}
+
+// payload returns the size bytes of payload of a table leaf cell starting at
+// cell, following the chain of overflow pages of what isn't stored locally.
+func (r *sqliteReader) payload(cell []byte, size int) []byte {
+	// The names of the file format: U is the usable size of a page, X the
+	// most payload stored locally, M the least before overflowing.
+	u := sqlitePageSize
+	x := u - 35
+	if size <= x {
+		return cell[:size]
+	}
+	m := (u-12)*32/255 - 23
+	local := m + (size-m)%(u-4)
+	if local > x {
+		local = m
+	}
+	payload := append([]byte{}, cell[:local]...)
+	next := binary.BigEndian.Uint32(cell[local:])
+	for len(payload) < size {
+		if next == 0 {
+			r.t.Fatalf("Overflow chain ends after %v of %v bytes", len(payload), size)
+		}
+		page, _ := r.page(next)
+		n := size - len(payload)
+		if n > u-4 {
+			n = u - 4
+		}
+		payload = append(payload, page[4:4+n]...)
+		next = binary.BigEndian.Uint32(page)
+	}
+	if next != 0 {
+		r.t.Errorf("Overflow chain continues to page %v after the payload", next)
+	}
+	return payload
+}
+
+// record decodes a record into nil, int64, float64, string and []byte
+// values.
+func (r *sqliteReader) record(payload []byte) []interface{} {
+	headerSize, i := readSQLiteVarint(payload)
+	var types []uint64
+	for i < int(headerSize) {
+		t, n := readSQLiteVarint(payload[i:])
+		types = append(types, t)
+		i += n
+	}
+	body := payload[headerSize:]
+	var values []interface{}
+	for _, t := range types {
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t <= 6:
+			n := []int{1, 2, 3, 4, 6, 8}[t-1]
+			v := int64(int8(body[0]))
+			for _, b := range body[1:n] {
+				v = v<<8 | int64(b)
+			}
+			values = append(values, v)
+			body = body[n:]
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(body)))
+			body = body[8:]
+		case t == 8 || t == 9:
+			values = append(values, int64(t-8))
+		case t >= 12 && t%2 == 0:
+			n := int(t-12) / 2
+			values = append(values, append([]byte{}, body[:n]...))
+			body = body[n:]
+		case t >= 13:
+			n := int(t-13) / 2
+			values = append(values, string(body[:n]))
+			body = body[n:]
+		default:
+			r.t.Fatalf("Unexpected serial type %v", t)
+		}
+	}
+	return values
+}
+
+// schema returns the rows of sqlite_master, on page 1, by name.
+func (r *sqliteReader) schema() map[string][]interface{} {
+	schema := map[string][]interface{}{}
+	for _, row := range r.rows(1) {
+		schema[row.values[1].(string)] = row.values
+	}
+	return schema
+}
+
+func TestWriteSQLite(t *testing.T) {
+	const smallSQL = "CREATE TABLE small (id INTEGER PRIMARY KEY, name TEXT UNIQUE)"
+	const bigSQL = "CREATE TABLE big (data BLOB, n INTEGER)"
+	// Enough rows for the leaves of big to need an interior page, and a
+	// blob which needs several overflow pages.
+	large := bytes.Repeat([]byte("electorate"), 3*sqlitePageSize/10)
+	big := &sqliteTable{Name: "big", SQL: bigSQL, RowIDColumn: -1}
+	for i := 0; i < 2000; i++ {
+		big.Rows = append(big.Rows, []interface{}{[]byte("polygon"), int64(i)})
+	}
+	big.Rows[1000][0] = large
+	tables := []*sqliteTable{
+		{
+			Name:        "small",
+			SQL:         smallSQL,
+			RowIDColumn: 0,
+			Rows:        [][]interface{}{{int64(3), "c"}, {int64(-1), "b"}, {int64(1000), "a"}},
+			Indexes:     [][]int{{1}},
+		},
+		big,
+	}
+	var b bytes.Buffer
+	if err := writeSQLite(&b, sqliteHeader{ApplicationID: 1, UserVersion: 2}, tables); err != nil {
+		t.Fatal(err)
+	}
+	r := &sqliteReader{t: t, db: b.Bytes()}
+	if pages := binary.BigEndian.Uint32(r.db[28:]); len(r.db) != int(pages)*sqlitePageSize {
+		t.Fatalf("Expected %v pages, got %v bytes", pages, len(r.db))
+	}
+
+	schema := r.schema()
+	expected := map[string][]interface{}{
+		"small":                    {"table", "small", "small", nil, smallSQL},
+		"sqlite_autoindex_small_1": {"index", "sqlite_autoindex_small_1", "small", nil, nil},
+		"big":                      {"table", "big", "big", nil, bigSQL},
+	}
+	if len(schema) != len(expected) {
+		t.Errorf("Expected %v schema rows, got %v", len(expected), schema)
+	}
+	for name, e := range expected {
+		row := schema[name]
+		if len(row) != 5 {
+			t.Errorf("Expected %v in the schema, got %v", name, row)
+			continue
+		}
+		// Root pages depend on the layout.
+		e[3] = row[3]
+		if !reflect.DeepEqual(row, e) {
+			t.Errorf("Expected %v, got %v", e, row)
+		}
+	}
+	root := func(name string) uint32 {
+		return uint32(schema[name][3].(int64))
+	}
+
+	// The rowid alias is NULL in the record.
+	rows := r.rows(root("small"))
+	if !reflect.DeepEqual(rows, []sqliteRow{
+		{rowID: -1, values: []interface{}{nil, "b"}},
+		{rowID: 3, values: []interface{}{nil, "c"}},
+		{rowID: 1000, values: []interface{}{nil, "a"}},
+	}) {
+		t.Errorf("Unexpected rows of small %v", rows)
+	}
+	entries := r.indexEntries(root("sqlite_autoindex_small_1"))
+	if !reflect.DeepEqual(entries, [][]interface{}{{"a", int64(1000)}, {"b", int64(-1)}, {"c", int64(3)}}) {
+		t.Errorf("Unexpected index entries %v", entries)
+	}
+
+	if _, header := r.page(root("big")); header[0] != 0x05 {
+		t.Errorf("Expected an interior root page, got %x", header[0])
+	}
+	rows = r.rows(root("big"))
+	if len(rows) != len(big.Rows) {
+		t.Fatalf("Expected %v rows of big, got %v", len(big.Rows), len(rows))
+	}
+	for i, row := range rows {
+		if row.rowID != int64(i+1) || !reflect.DeepEqual(row.values, big.Rows[i]) {
+			t.Fatalf("Expected row %v to be %v, got %v: %v", i+1, big.Rows[i], row.rowID, row.values)
+		}
+	}
+}
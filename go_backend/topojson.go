@@ -0,0 +1,314 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// This file converts a feature collection of (multi) polygons to TopoJSON
+// (https://github.com/topojson/topojson-specification). Borders shared by
+// neighbouring electorates are stored once as arcs which both electorates
+// reference, and coordinates are quantized and delta encoded.
+
+// TopoJSONQuantization is the number of distinct values along each axis of
+// the quantized coordinates. Within Australia this is precise to ~40 metres
+// for the whole country, and much finer for smaller selections.
+const TopoJSONQuantization = 1e5
+
+// TopoJSONObjectName is the name of the geometry collection holding the
+// features in a topology.
+const TopoJSONObjectName = "electorates"
+
+// TopoJSONTransform maps quantized coordinates back to longitude, latitude.
+type TopoJSONTransform struct {
+	Scale     [2]float64 `json:"scale"`
+	Translate [2]float64 `json:"translate"`
+}
+
+// TopoJSONGeometry is a geometry object referencing arcs by index, a negative
+// index ^i (i.e. -i-1) references arc i reversed.
+type TopoJSONGeometry struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Arcs       [][][]int              `json:"arcs,omitempty"`
+	Geometries []*TopoJSONGeometry    `json:"geometries,omitempty"`
+}
+
+// Topology is a TopoJSON topology object.
+type Topology struct {
+	Type        string                       `json:"type"`
+	BoundingBox []float64                    `json:"bbox,omitempty"`
+	Transform   *TopoJSONTransform           `json:"transform"`
+	Objects     map[string]*TopoJSONGeometry `json:"objects"`
+	// Arcs are delta encoded: each position after the first is relative
+	// to the previous one.
+	Arcs [][][2]int `json:"arcs"`
+}
+
+type quantizedPoint [2]int
+
+// topologyBuilder extracts shared arcs out of quantized linear rings.
+type topologyBuilder struct {
+	rings [][]quantizedPoint
+	// junctions are the points at which rings must be cut into arcs:
+	// points shared by rings which arrive from or continue to different
+	// points.
+	junctions map[quantizedPoint]bool
+	arcs      [][]quantizedPoint
+	arcIndex  map[string]int
+}
+
+func arcKey(arc []quantizedPoint) string {
+	parts := make([]string, len(arc))
+	for i, p := range arc {
+		parts[i] = strconv.Itoa(p[0]) + "," + strconv.Itoa(p[1])
+	}
+	return strings.Join(parts, ";")
+}
+
+func reverseArc(arc []quantizedPoint) []quantizedPoint {
+	reversed := make([]quantizedPoint, len(arc))
+	for i, p := range arc {
+		reversed[len(arc)-1-i] = p
+	}
+	return reversed
+}
+
+// findJunctions marks every point which is visited with different
+// neighbours in different places.
+func (tb *topologyBuilder) findJunctions() {
+	type neighbours [2]quantizedPoint
+	seen := make(map[quantizedPoint]neighbours)
+	tb.junctions = make(map[quantizedPoint]bool)
+	for _, ring := range tb.rings {
+		n := len(ring)
+		for i, p := range ring {
+			a, b := ring[(i+n-1)%n], ring[(i+1)%n]
+			// Neighbours are unordered, so that a border walked in
+			// opposite directions by two rings matches.
+			if b[0] < a[0] || (b[0] == a[0] && b[1] < a[1]) {
+				a, b = b, a
+			}
+			if prev, ok := seen[p]; ok {
+				if prev != (neighbours{a, b}) {
+					tb.junctions[p] = true
+				}
+				continue
+			}
+			seen[p] = neighbours{a, b}
+		}
+	}
+}
+
+// addArc returns the index of arc, reusing an existing arc if the same (or
+// reversed) sequence of points was already added.
+func (tb *topologyBuilder) addArc(arc []quantizedPoint) int {
+	if i, ok := tb.arcIndex[arcKey(arc)]; ok {
+		return i
+	}
+	if i, ok := tb.arcIndex[arcKey(reverseArc(arc))]; ok {
+		return ^i
+	}
+	i := len(tb.arcs)
+	tb.arcs = append(tb.arcs, arc)
+	tb.arcIndex[arcKey(arc)] = i
+	return i
+}
+
+// ringArcs cuts a ring (not closed, i.e. without the repeated first point)
+// at its junctions and returns the indices of its arcs.
+func (tb *topologyBuilder) ringArcs(ring []quantizedPoint) []int {
+	n := len(ring)
+	start := -1
+	for i, p := range ring {
+		if tb.junctions[p] {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		// No junctions, the ring is a single closed arc. Start it at
+		// its smallest point so that the same ring in another feature
+		// (e.g. an enclave's border) produces the same arc.
+		start = 0
+		for i, p := range ring {
+			s := ring[start]
+			if p[0] < s[0] || (p[0] == s[0] && p[1] < s[1]) {
+				start = i
+			}
+		}
+		arc := make([]quantizedPoint, 0, n+1)
+		arc = append(arc, ring[start:]...)
+		arc = append(arc, ring[:start+1]...)
+		return []int{tb.addArc(arc)}
+	}
+	var arcs []int
+	arc := []quantizedPoint{ring[start]}
+	for i := 1; i <= n; i++ {
+		p := ring[(start+i)%n]
+		arc = append(arc, p)
+		if tb.junctions[p] {
+			arcs = append(arcs, tb.addArc(arc))
+			arc = []quantizedPoint{p}
+		}
+	}
+	return arcs
+}
+
+type quantizer struct {
+	translate [2]float64
+	scale     [2]float64
+}
+
+func newQuantizer(bbox []float64) quantizer {
+	q := quantizer{translate: [2]float64{bbox[0], bbox[1]}}
+	for i := range q.scale {
+		q.scale[i] = (bbox[i+2] - bbox[i]) / (TopoJSONQuantization - 1)
+		if q.scale[i] == 0 {
+			q.scale[i] = 1
+		}
+	}
+	return q
+}
+
+func (q quantizer) quantize(position []float64) quantizedPoint {
+	return quantizedPoint{
+		int(math.Floor((position[0]-q.translate[0])/q.scale[0] + 0.5)),
+		int(math.Floor((position[1]-q.translate[1])/q.scale[1] + 0.5)),
+	}
+}
+
+// quantizeRing quantizes a GeoJSON linear ring, dropping repeated points
+// (including the closing point). It returns nil for rings that collapse.
+func (q quantizer) quantizeRing(linearRing [][]float64) []quantizedPoint {
+	var ring []quantizedPoint
+	for _, position := range linearRing {
+		p := q.quantize(position)
+		if len(ring) > 0 && ring[len(ring)-1] == p {
+			continue
+		}
+		ring = append(ring, p)
+	}
+	if len(ring) > 1 && ring[0] == ring[len(ring)-1] {
+		ring = ring[:len(ring)-1]
+	}
+	if len(ring) < 3 {
+		return nil
+	}
+	return ring
+}
+
+// addPolygon quantizes the rings of a GeoJSON polygon and returns their
+// indices in tb.rings, or nil if the exterior ring collapses.
+func (tb *topologyBuilder) addPolygon(q quantizer, polygon [][][]float64) []int {
+	var ringIndices []int
+	for i, linearRing := range polygon {
+		ring := q.quantizeRing(linearRing)
+		if ring == nil {
+			if i == 0 {
+				return nil
+			}
+			continue
+		}
+		ringIndices = append(ringIndices, len(tb.rings))
+		tb.rings = append(tb.rings, ring)
+	}
+	return ringIndices
+}
+
+// convertToTopology converts a feature collection of Polygon and MultiPolygon
+// features to a topology with a single geometry collection.
+func convertToTopology(fc *geojson.FeatureCollection) (*Topology, error) {
+	if len(fc.BoundingBox) != 4 {
+		return nil, fmt.Errorf("Feature collection requires a bbox")
+	}
+	q := newQuantizer(fc.BoundingBox)
+	tb := &topologyBuilder{arcIndex: make(map[string]int)}
+	// Quantize every polygon first, keeping the ring indices for each of
+	// them, as junctions can only be found once all rings are known.
+	featurePolygons := make([][][]int, len(fc.Features))
+	for i, f := range fc.Features {
+		var polygons [][][][]float64
+		switch {
+		case f.Geometry == nil:
+		case f.Geometry.IsMultiPolygon():
+			polygons = f.Geometry.MultiPolygon
+		case f.Geometry.IsPolygon():
+			polygons = [][][][]float64{f.Geometry.Polygon}
+		default:
+			return nil, fmt.Errorf("Unsupported geometry type %v", f.Geometry.Type)
+		}
+		for _, polygon := range polygons {
+			if ringIndices := tb.addPolygon(q, polygon); ringIndices != nil {
+				featurePolygons[i] = append(featurePolygons[i], ringIndices)
+			}
+		}
+	}
+	tb.findJunctions()
+	collection := &TopoJSONGeometry{Type: "GeometryCollection"}
+	for i, f := range fc.Features {
+		var arcs [][][]int
+		for _, ringIndices := range featurePolygons[i] {
+			var polygon [][]int
+			for _, ringIndex := range ringIndices {
+				polygon = append(polygon, tb.ringArcs(tb.rings[ringIndex]))
+			}
+			arcs = append(arcs, polygon)
+		}
+		collection.Geometries = append(collection.Geometries, &TopoJSONGeometry{
+			Type:       "MultiPolygon",
+			ID:         f.ID,
+			Properties: f.Properties,
+			Arcs:       arcs,
+		})
+	}
+	topology := &Topology{
+		Type:        "Topology",
+		BoundingBox: fc.BoundingBox,
+		Transform: &TopoJSONTransform{
+			Scale:     q.scale,
+			Translate: q.translate,
+		},
+		Objects: map[string]*TopoJSONGeometry{TopoJSONObjectName: collection},
+		Arcs:    make([][][2]int, len(tb.arcs)),
+	}
+	for i, arc := range tb.arcs {
+		encoded := make([][2]int, len(arc))
+		prev := quantizedPoint{0, 0}
+		for j, p := range arc {
+			encoded[j] = [2]int{p[0] - prev[0], p[1] - prev[1]}
+			prev = p
+		}
+		topology.Arcs[i] = encoded
+	}
+	return topology, nil
+}
+
+// MarshalJSON returns JSON in a byte slice from a given topology.
+func (t *Topology) MarshalJSON() ([]byte, error) {
+	// Must use *t to avoid infinite loop.
+	return json.Marshal(*t)
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"testing"
+
+	"github.com/paulmach/go.geojson"
+)
+
+func squareFeature(id string, x, y float64) *geojson.Feature {
+	f := geojson.NewMultiPolygonFeature([][][]float64{{
+		{x, y}, {x + 1, y}, {x + 1, y + 1}, {x, y + 1}, {x, y},
+	}})
+	f.ID = id
+	return f
+}
+
+func TestConvertToTopologySharesBorders(t *testing.T) {
+	fc := geojson.NewFeatureCollection()
+	fc.AddFeature(squareFeature("a", 0, 0))
+	fc.AddFeature(squareFeature("b", 1, 0))
+	fc.BoundingBox = []float64{0, 0, 2, 1}
+	topology, err := convertToTopology(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The shared border is a single arc, plus an arc for each square's
+	// remaining border.
+	if len(topology.Arcs) != 3 {
+		t.Fatalf("Expected 3 arcs, got %v: %v", len(topology.Arcs), topology.Arcs)
+	}
+	geometries := topology.Objects[TopoJSONObjectName].Geometries
+	if len(geometries) != 2 {
+		t.Fatalf("Expected 2 geometries, got %v", len(geometries))
+	}
+	a := geometries[0].Arcs[0][0]
+	b := geometries[1].Arcs[0][0]
+	shared := false
+	for _, i := range a {
+		for _, j := range b {
+			// The neighbours walk the shared border in opposite
+			// directions.
+			if i == ^j {
+				shared = true
+			}
+		}
+	}
+	if !shared {
+		t.Errorf("Expected a reversed shared arc, got %v and %v", a, b)
+	}
+}
+
+func TestConvertToTopologyClosedArc(t *testing.T) {
+	fc := geojson.NewFeatureCollection()
+	fc.AddFeature(squareFeature("a", 0, 0))
+	fc.BoundingBox = []float64{0, 0, 1, 1}
+	topology, err := convertToTopology(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topology.Arcs) != 1 {
+		t.Fatalf("Expected 1 arc, got %v", topology.Arcs)
+	}
+	// The arc is closed, and its deltas add up to the first position.
+	arc := topology.Arcs[0]
+	if len(arc) != 5 {
+		t.Fatalf("Expected 5 positions, got %v", arc)
+	}
+	sum := [2]int{}
+	for _, d := range arc[1:] {
+		sum[0] += d[0]
+		sum[1] += d[1]
+	}
+	if sum != [2]int{0, 0} {
+		t.Errorf("Expected closed arc, got %v", arc)
+	}
+}
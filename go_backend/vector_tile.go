@@ -0,0 +1,442 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	shp "github.com/jonas-p/go-shp"
+	"github.com/paulmach/go.geojson"
+)
+
+// This file implements just enough of the Mapbox Vector Tile specification
+// (https://github.com/mapbox/vector-tile-spec/tree/master/2.1) to serve
+// electorate polygons and polling place points. The protobuf encoding is
+// written by hand, the schema is small and stable.
+
+// VectorTileExtent is the number of integer units across a tile.
+const VectorTileExtent = 4096
+
+// VectorTileBuffer is the number of units outside the tile extent that
+// geometry is kept for when clipping, hiding seams between tiles.
+const VectorTileBuffer = 64
+
+// MaxTileZoom is the highest tile zoom level served.
+const MaxTileZoom = 22
+
+const (
+	vectorTileLayerElectorates   = "electorates"
+	vectorTileLayerPollingPlaces = "polling_places"
+)
+
+// Geometry types, as defined in vector_tile.proto.
+const (
+	vectorTilePoint   = 1
+	vectorTilePolygon = 3
+)
+
+// Geometry commands, as defined in the specification.
+const (
+	commandMoveTo    = 1
+	commandLineTo    = 2
+	commandClosePath = 7
+)
+
+func zigzag(v int) uint32 {
+	v32 := int32(v)
+	return uint32((v32 << 1) ^ (v32 >> 31))
+}
+
+func command(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count<<3)
+}
+
+// tileCoord identifies a tile in the web mercator tiling scheme.
+type tileCoord struct {
+	z, x, y int
+}
+
+func newTileCoord(z, x, y int) (tileCoord, error) {
+	if z < 0 || z > MaxTileZoom {
+		return tileCoord{}, fmt.Errorf("Tile zoom %v out of range", z)
+	}
+	n := 1 << uint(z)
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return tileCoord{}, fmt.Errorf("Tile %v/%v/%v out of range", z, x, y)
+	}
+	return tileCoord{z, x, y}, nil
+}
+
+// project returns the position of lng, lat in tile units, relative to the
+// tile's top left corner.
+func (t tileCoord) project(lng, lat float64) (float64, float64) {
+	n := float64(int(1) << uint(t.z))
+	x := (lng + 180) / 360 * n
+	latRad := lat * math.Pi / 180
+	y := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n
+	return (x - float64(t.x)) * VectorTileExtent, (y - float64(t.y)) * VectorTileExtent
+}
+
+// unproject is the reverse of project.
+func (t tileCoord) unproject(px, py float64) (float64, float64) {
+	n := float64(int(1) << uint(t.z))
+	x := float64(t.x) + px/VectorTileExtent
+	y := float64(t.y) + py/VectorTileExtent
+	lng := x/n*360 - 180
+	lat := math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+	return lng, lat
+}
+
+// bounds returns the tile's bounding box in lng, lat, including the buffer.
+func (t tileCoord) bounds() shp.Box {
+	minLng, maxLat := t.unproject(-VectorTileBuffer, -VectorTileBuffer)
+	maxLng, minLat := t.unproject(VectorTileExtent+VectorTileBuffer, VectorTileExtent+VectorTileBuffer)
+	return shp.Box{MinX: minLng, MinY: minLat, MaxX: maxLng, MaxY: maxLat}
+}
+
+type tilePoint struct {
+	x, y float64
+}
+
+// clipRing clips a linear ring to the tile extent plus buffer using the
+// Sutherland-Hodgman algorithm. Since the clip region is convex, the result
+// is a single (possibly empty) ring.
+func clipRing(ring []tilePoint) []tilePoint {
+	const min = -VectorTileBuffer
+	const max = VectorTileExtent + VectorTileBuffer
+	edges := []struct {
+		inside    func(p tilePoint) bool
+		intersect func(a, b tilePoint) tilePoint
+	}{
+		{func(p tilePoint) bool { return p.x >= min }, func(a, b tilePoint) tilePoint {
+			return tilePoint{min, a.y + (b.y-a.y)*(min-a.x)/(b.x-a.x)}
+		}},
+		{func(p tilePoint) bool { return p.x <= max }, func(a, b tilePoint) tilePoint {
+			return tilePoint{max, a.y + (b.y-a.y)*(max-a.x)/(b.x-a.x)}
+		}},
+		{func(p tilePoint) bool { return p.y >= min }, func(a, b tilePoint) tilePoint {
+			return tilePoint{a.x + (b.x-a.x)*(min-a.y)/(b.y-a.y), min}
+		}},
+		{func(p tilePoint) bool { return p.y <= max }, func(a, b tilePoint) tilePoint {
+			return tilePoint{a.x + (b.x-a.x)*(max-a.y)/(b.y-a.y), max}
+		}},
+	}
+	for _, edge := range edges {
+		if len(ring) == 0 {
+			return nil
+		}
+		var clipped []tilePoint
+		prev := ring[len(ring)-1]
+		for _, p := range ring {
+			if edge.inside(p) {
+				if !edge.inside(prev) {
+					clipped = append(clipped, edge.intersect(prev, p))
+				}
+				clipped = append(clipped, p)
+			} else if edge.inside(prev) {
+				clipped = append(clipped, edge.intersect(prev, p))
+			}
+			prev = p
+		}
+		ring = clipped
+	}
+	return ring
+}
+
+type tileIntPoint struct {
+	x, y int
+}
+
+// roundRing rounds a clipped ring to integer tile units, dropping repeated
+// points and the closing point. It returns nil if fewer than 3 points remain.
+func roundRing(ring []tilePoint) []tileIntPoint {
+	var rounded []tileIntPoint
+	for _, p := range ring {
+		ip := tileIntPoint{int(math.Floor(p.x + 0.5)), int(math.Floor(p.y + 0.5))}
+		if len(rounded) > 0 && rounded[len(rounded)-1] == ip {
+			continue
+		}
+		rounded = append(rounded, ip)
+	}
+	if len(rounded) > 1 && rounded[0] == rounded[len(rounded)-1] {
+		rounded = rounded[:len(rounded)-1]
+	}
+	if len(rounded) < 3 {
+		return nil
+	}
+	return rounded
+}
+
+// ringArea returns twice the signed area of a ring. In tile coordinates (y
+// pointing down) clockwise rings have a positive area.
+func ringArea(ring []tileIntPoint) int {
+	area := 0
+	prev := ring[len(ring)-1]
+	for _, p := range ring {
+		area += prev.x*p.y - p.x*prev.y
+		prev = p
+	}
+	return area
+}
+
+// geometryEncoder accumulates geometry commands, tracking the cursor as
+// coordinates are delta encoded.
+type geometryEncoder struct {
+	geometry []uint32
+	cursor   tileIntPoint
+}
+
+func (g *geometryEncoder) moveTo(p tileIntPoint) {
+	g.geometry = append(g.geometry, command(commandMoveTo, 1),
+		zigzag(p.x-g.cursor.x), zigzag(p.y-g.cursor.y))
+	g.cursor = p
+}
+
+func (g *geometryEncoder) ring(ring []tileIntPoint) {
+	g.moveTo(ring[0])
+	g.geometry = append(g.geometry, command(commandLineTo, len(ring)-1))
+	for _, p := range ring[1:] {
+		g.geometry = append(g.geometry, zigzag(p.x-g.cursor.x), zigzag(p.y-g.cursor.y))
+		g.cursor = p
+	}
+	g.geometry = append(g.geometry, command(commandClosePath, 1))
+}
+
+// polygon adds a polygon, the first ring being the exterior ring. Per the
+// specification exterior rings are wound clockwise and interior rings
+// counter-clockwise, regardless of the source winding order. It returns false
+// if the exterior ring is clipped out of the tile.
+func (g *geometryEncoder) polygon(rings [][]tileIntPoint) bool {
+	if len(rings) == 0 || rings[0] == nil {
+		return false
+	}
+	for i, ring := range rings {
+		if ring == nil {
+			continue
+		}
+		exterior := i == 0
+		if area := ringArea(ring); area == 0 || (area > 0) != exterior {
+			reverseRing(ring)
+		}
+		g.ring(ring)
+	}
+	return true
+}
+
+func reverseRing(ring []tileIntPoint) {
+	for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+		ring[i], ring[j] = ring[j], ring[i]
+	}
+}
+
+// vectorTileLayer holds the encoded features of a single layer, along with
+// the keys and values tables shared by its features' tags.
+type vectorTileLayer struct {
+	name       string
+	features   [][]byte
+	keys       []string
+	keyIndex   map[string]uint32
+	values     []interface{}
+	valueIndex map[interface{}]uint32
+}
+
+func newVectorTileLayer(name string) *vectorTileLayer {
+	return &vectorTileLayer{
+		name:       name,
+		keyIndex:   make(map[string]uint32),
+		valueIndex: make(map[interface{}]uint32),
+	}
+}
+
+// normalizeTileValue converts a property value to one of the types which
+// can be encoded in a tile (string, float64, int64 or bool).
+func normalizeTileValue(v interface{}) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case ElectorateID:
+		return string(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case bool:
+		return v, true
+	}
+	return nil, false
+}
+
+func (l *vectorTileLayer) tags(properties map[string]interface{}) []uint32 {
+	// Sort the keys so that identical requests produce identical tiles.
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var tags []uint32
+	for _, k := range keys {
+		v := properties[k]
+		value, ok := normalizeTileValue(v)
+		if !ok {
+			continue
+		}
+		keyIndex, ok := l.keyIndex[k]
+		if !ok {
+			keyIndex = uint32(len(l.keys))
+			l.keys = append(l.keys, k)
+			l.keyIndex[k] = keyIndex
+		}
+		valueIndex, ok := l.valueIndex[value]
+		if !ok {
+			valueIndex = uint32(len(l.values))
+			l.values = append(l.values, value)
+			l.valueIndex[value] = valueIndex
+		}
+		tags = append(tags, keyIndex, valueIndex)
+	}
+	return tags
+}
+
+func (l *vectorTileLayer) addFeature(geomType int, geometry []uint32, properties map[string]interface{}) {
+	var f []byte
+	f = appendPackedField(f, 2, l.tags(properties))
+	f = appendVarintField(f, 3, uint64(geomType))
+	f = appendPackedField(f, 4, geometry)
+	l.features = append(l.features, f)
+}
+
+func encodeTileValue(v interface{}) []byte {
+	var b []byte
+	switch v := v.(type) {
+	case string:
+		b = appendBytesField(b, 1, []byte(v))
+	case float64:
+		b = appendFixed64Field(b, 3, math.Float64bits(v))
+	case int64:
+		b = appendVarintField(b, 6, zigzag64(v))
+	case bool:
+		bv := uint64(0)
+		if v {
+			bv = 1
+		}
+		b = appendVarintField(b, 7, bv)
+	}
+	return b
+}
+
+func (l *vectorTileLayer) encode() []byte {
+	var b []byte
+	b = appendVarintField(b, 15, 2)
+	b = appendBytesField(b, 1, []byte(l.name))
+	for _, f := range l.features {
+		b = appendBytesField(b, 2, f)
+	}
+	for _, k := range l.keys {
+		b = appendBytesField(b, 3, []byte(k))
+	}
+	for _, v := range l.values {
+		b = appendBytesField(b, 4, encodeTileValue(v))
+	}
+	b = appendVarintField(b, 5, VectorTileExtent)
+	return b
+}
+
+// electoratePolygonsToTileGeometry projects and clips an electorate's
+// polygons to the tile, returning the encoded geometry or nil if nothing of
+// the electorate is within the tile.
+func electoratePolygonsToTileGeometry(t tileCoord, eps []*ElectoratePolygon) []uint32 {
+	var g geometryEncoder
+	for _, ep := range eps {
+		var rings [][]tileIntPoint
+		parts := append(ep.Parts, ep.NumPoints)
+		for i := 1; i <= int(ep.NumParts); i++ {
+			points := ep.Points[parts[i-1]:parts[i]]
+			ring := make([]tilePoint, len(points))
+			for j, p := range points {
+				x, y := t.project(p.X, p.Y)
+				ring[j] = tilePoint{x, y}
+			}
+			rings = append(rings, roundRing(clipRing(ring)))
+		}
+		g.polygon(rings)
+	}
+	return g.geometry
+}
+
+// queryTile returns a Mapbox Vector Tile with an electorates layer holding
+// the polygons of the electorates intersecting the tile, at the best zoom
+// bucket for the tile's zoom level, and a polling places layer holding the
+// polling places and groups visible at that zoom level.
+func queryTile(t tileCoord) ([]byte, error) {
+	bbox := t.bounds()
+	rect, err := BboxToRect(&bbox)
+	if err != nil {
+		return nil, err
+	}
+	// The viewport query already knows which electorates and polling
+	// places are visible in a rect at a given zoom.
+	vr := queryViewport(rect, chooseBestZoomBucket(t.z), t.z, true, nil)
+
+	electorateLayer := newVectorTileLayer(vectorTileLayerElectorates)
+	for _, spatial := range vr.electorates {
+		e, ok := spatial.(*Electorate)
+		if !ok {
+			continue
+		}
+		geometry := electoratePolygonsToTileGeometry(t, e.polygons[vr.zoom])
+		if geometry == nil {
+			continue
+		}
+		electorateLayer.addFeature(vectorTilePolygon, geometry, map[string]interface{}{
+			"id":        string(e.id),
+			"name":      e.name,
+			"state":     e.state,
+			"area_sqkm": e.areaSqkm,
+		})
+	}
+
+	pollingPlaceLayer := newVectorTileLayer(vectorTileLayerPollingPlaces)
+	for _, f := range vr.Features {
+		if f.Geometry == nil || f.Geometry.Type != geojson.GeometryPoint {
+			continue
+		}
+		x, y := t.project(f.Geometry.Point[0], f.Geometry.Point[1])
+		var g geometryEncoder
+		g.moveTo(tileIntPoint{int(math.Floor(x + 0.5)), int(math.Floor(y + 0.5))})
+		properties := map[string]interface{}{"id": f.ID}
+		for k, v := range f.Properties {
+			properties[k] = v
+		}
+		pollingPlaceLayer.addFeature(vectorTilePoint, g.geometry, properties)
+	}
+
+	var tile []byte
+	for _, l := range []*vectorTileLayer{electorateLayer, pollingPlaceLayer} {
+		if len(l.features) == 0 {
+			continue
+		}
+		tile = appendBytesField(tile, 3, l.encode())
+	}
+	return tile, nil
+}
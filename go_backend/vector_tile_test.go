@@ -0,0 +1,98 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTileCoordProjectUnproject(t *testing.T) {
+	tc, err := newTileCoord(12, 3768, 2457)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lng, lat := 151.2153, -33.8568
+	x, y := tc.project(lng, lat)
+	if x < 0 || x > VectorTileExtent || y < 0 || y > VectorTileExtent {
+		t.Errorf("Expected %v,%v to be within tile %v, got %v,%v", lat, lng, tc, x, y)
+	}
+	lng2, lat2 := tc.unproject(x, y)
+	if math.Abs(lng2-lng) > 1e-9 || math.Abs(lat2-lat) > 1e-9 {
+		t.Errorf("Expected %v,%v after round trip, got %v,%v", lat, lng, lat2, lng2)
+	}
+}
+
+func TestNewTileCoordOutOfRange(t *testing.T) {
+	for _, c := range [][3]int{{-1, 0, 0}, {2, 4, 0}, {2, 0, -1}, {MaxTileZoom + 1, 0, 0}} {
+		if _, err := newTileCoord(c[0], c[1], c[2]); err == nil {
+			t.Errorf("Expected an error for tile %v", c)
+		}
+	}
+}
+
+func TestClipRing(t *testing.T) {
+	const max = VectorTileExtent + VectorTileBuffer
+	// A square twice the size of the tile, centred on the tile's top left
+	// corner, should be clipped to the buffered quarter inside the tile.
+	ring := []tilePoint{
+		{-VectorTileExtent, -VectorTileExtent},
+		{VectorTileExtent * 2, -VectorTileExtent},
+		{VectorTileExtent * 2, VectorTileExtent * 2},
+		{-VectorTileExtent, VectorTileExtent * 2},
+	}
+	clipped := roundRing(clipRing(ring))
+	if len(clipped) != 4 {
+		t.Fatalf("Expected 4 points, got %v", clipped)
+	}
+	for _, p := range clipped {
+		if p.x != -VectorTileBuffer && p.x != max || p.y != -VectorTileBuffer && p.y != max {
+			t.Errorf("Expected point on the clip boundary, got %v", p)
+		}
+	}
+	// A ring entirely outside the tile is dropped.
+	outside := []tilePoint{{max + 10, 0}, {max + 20, 0}, {max + 20, 10}}
+	if clipped := roundRing(clipRing(outside)); clipped != nil {
+		t.Errorf("Expected ring to be clipped out, got %v", clipped)
+	}
+}
+
+func TestGeometryEncoderPolygonWinding(t *testing.T) {
+	var g geometryEncoder
+	// Counter-clockwise in tile coordinates, must be reversed.
+	ring := []tileIntPoint{{0, 0}, {0, 10}, {10, 10}, {10, 0}}
+	if !g.polygon([][]tileIntPoint{ring}) {
+		t.Fatal("Expected polygon to be encoded")
+	}
+	if area := ringArea(ring); area <= 0 {
+		t.Errorf("Expected exterior ring to be clockwise, area was %v", area)
+	}
+	expected := []uint32{
+		command(commandMoveTo, 1), zigzag(10), zigzag(0),
+		command(commandLineTo, 3), zigzag(0), zigzag(10), zigzag(-10), zigzag(0), zigzag(0), zigzag(-10),
+		command(commandClosePath, 1),
+	}
+	if len(g.geometry) != len(expected) {
+		t.Fatalf("Expected geometry %v, got %v", expected, g.geometry)
+	}
+	for i := range expected {
+		if g.geometry[i] != expected[i] {
+			t.Fatalf("Expected geometry %v, got %v", expected, g.geometry)
+		}
+	}
+}
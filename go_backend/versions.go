@@ -0,0 +1,89 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// apiVersion is a version of the API, served under Prefix.
+type apiVersion struct {
+	Prefix string
+	Routes func() []*apiRoute
+}
+
+// apiVersions are served side by side. Response shapes only change in a new
+// version, so that embedded maps on partner sites keep working: add it here
+// with its own routes, typically apiRoutes() with the changed handlers
+// replaced.
+var apiVersions = []*apiVersion{
+	{Prefix: "/v1", Routes: apiRoutes},
+}
+
+// LegacyAPIVersion is the version also served without a prefix, as it was
+// before the API was versioned.
+const LegacyAPIVersion = "/v1"
+
+// newAPIRouter routes each of versions under its prefix, and the legacy
+// version at the root. All versions share limiter's buckets.
+func newAPIRouter(versions []*apiVersion, limiter *rateLimiter) *mux.Router {
+	r := mux.NewRouter()
+	for _, v := range versions {
+		registerRoutes(r.PathPrefix(v.Prefix).Subrouter(), v.Prefix, v.Prefix, v.Routes(), limiter)
+		if v.Prefix == LegacyAPIVersion {
+			registerRoutes(r, "", v.Prefix, v.Routes(), limiter)
+		}
+	}
+	r.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	return r
+}
+
+// APIPatterns returns the http.ServeMux patterns which should be routed to
+// the handler returned by NewAPIHandler: the health and metrics endpoints, a
+// subtree for each version, and the unprefixed paths of the legacy version.
+func APIPatterns() []string {
+	var patterns []string
+	seen := map[string]bool{}
+	add := func(pattern string) {
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	add("/healthz")
+	add("/readyz")
+	add("/metrics")
+	for _, v := range apiVersions {
+		add(v.Prefix + "/")
+		if v.Prefix != LegacyAPIVersion {
+			continue
+		}
+		for _, route := range append(v.Routes(), openAPIRoute()) {
+			// Paths with variables are served as a subtree.
+			pattern := route.Path
+			if i := strings.Index(pattern, "{"); i >= 0 {
+				pattern = pattern[:i]
+			}
+			add(pattern)
+		}
+	}
+	return patterns
+}
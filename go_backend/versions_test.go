@@ -0,0 +1,88 @@
+/*
+ * Copyright 2016 Google Inc. All rights reserved.
+ *
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy
+ * of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations
+ * under the License.
+ */
+
+package election
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestVersionsSideBySide(t *testing.T) {
+	v2Routes := func() []*apiRoute {
+		routes := stubRoutes()
+		for _, route := range routes {
+			if route.Path == "/zoom_buckets" {
+				route.Handler = func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusTeapot)
+				}
+			}
+		}
+		return routes
+	}
+	r := newAPIRouter([]*apiVersion{
+		{Prefix: "/v1", Routes: stubRoutes},
+		{Prefix: "/v2", Routes: v2Routes},
+	}, nil)
+	tests := []struct {
+		url    string
+		status int
+	}{
+		{"/zoom_buckets", http.StatusOK},
+		{"/v1/zoom_buckets", http.StatusOK},
+		{"/v2/zoom_buckets", http.StatusTeapot},
+		{"/v3/zoom_buckets", http.StatusNotFound},
+		{"/v2/openapi.json", http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.status {
+			t.Errorf("%v: expected status %v, got %v", tt.url, tt.status, w.Code)
+		}
+	}
+}
+
+func TestAPIPatterns(t *testing.T) {
+	expected := []string{
+		"/healthz",
+		"/readyz",
+		"/metrics",
+		"/v1/",
+		"/electorates/",
+		"/location",
+		"/locations",
+		"/viewport/",
+		"/zoom_buckets",
+		"/polling_places",
+		"/polling_places.csv",
+		"/polling_places.kml",
+		"/polling_places.gpx",
+		"/nearest_polling_places",
+		"/postcode/",
+		"/suburb/",
+		"/search",
+		"/graphql",
+		"/tiles/",
+		"/openapi.json",
+	}
+	if patterns := APIPatterns(); !reflect.DeepEqual(patterns, expected) {
+		t.Errorf("Expected %v, got %v", expected, patterns)
+	}
+}
@@ -17,6 +17,7 @@ package main
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"os"
 
@@ -30,6 +31,8 @@ import (
 const BaseDistFolder = "Dist"
 
 func main() {
+	// Results are held in memory, which works in this single process.
+	election.ServeResults()
 	// LoggingHandler - Helpful for local development / debugging.
 	index := handlers.LoggingHandler(
 		os.Stdout,
@@ -37,19 +40,22 @@ func main() {
 	api := handlers.LoggingHandler(
 		os.Stdout,
 		election.CommonHeadersMiddleware(
-			election.NewAPIHandler()))
-	rootSlash := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// The "/" pattern matches everything, so we need to check
-		// that we're at the root here.
-		if r.URL.Path == "/" {
-			index.ServeHTTP(w, r)
-			return
-		}
-		api.ServeHTTP(w, r)
-	})
-	http.Handle("/", rootSlash)
+			election.CompressionMiddleware(
+				election.NewBackgroundAPIHandler())))
+	for _, pattern := range election.APIPatterns() {
+		http.Handle(pattern, api)
+	}
+	http.Handle("/", index)
 	http.Handle("/embed", index)
 	http.Handle("/static/", http.FileServer(http.Dir(BaseDistFolder)))
 	//http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	// The gRPC API, see go_backend/election.proto.
+	grpcListener, err := net.Listen("tcp", ":8091")
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		log.Fatal(election.NewGRPCServer().Serve(grpcListener))
+	}()
 	log.Fatal(http.ListenAndServe(":8090", nil))
 }